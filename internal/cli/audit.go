@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// runAudit executes the audit command: it prints the permissions of the
+// configuration directory and the files ccc keeps in it, and fails if any of
+// them can be read or written by other users. Access to files that should be
+// private but hold no secrets is only a warning.
+func runAudit() error {
+	checks, err := config.CheckPermissions()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Permissions:")
+	problems := 0
+	for _, check := range checks {
+		if !check.Exists {
+			fmt.Printf("  %-11s %s (not found)\n", "", check.Path)
+			continue
+		}
+		problem := check.Problem()
+		if warning := check.Warning(); warning != "" {
+			fmt.Printf("  %-11s %s: %s (warning, it holds no secrets)\n", check.Mode, check.Path, warning)
+			fmt.Printf("  %-11s fix with: chmod %04o %s\n", "", check.SuggestedMode(), check.Path)
+			continue
+		}
+		if problem == "" {
+			fmt.Printf("  %-11s %s\n", check.Mode, check.Path)
+			continue
+		}
+		problems++
+		fmt.Printf("  %-11s %s: %s\n", check.Mode, check.Path, problem)
+		fmt.Printf("  %-11s fix with: chmod %04o %s\n", "", check.SuggestedMode(), check.Path)
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d permission problem(s) found", problems)
+	}
+	fmt.Println("\nNo permission problems found.")
+	return nil
+}
+
+// warnConfigPermissions prints a warning if the file holding the API tokens
+// can be read by other users.
+func warnConfigPermissions() {
+	if path, mode, exposed := config.TokensExposed(); exposed {
+		fmt.Fprintf(os.Stderr, "Warning: %s holds API tokens but is accessible by group or others (mode %04o).\n", path, mode)
+		fmt.Fprintf(os.Stderr, "Fix with: chmod 600 %s (run `ccc audit` to check all files)\n", path)
+	}
+}
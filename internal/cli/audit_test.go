@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestRunAudit(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if cmd := Parse([]string{"audit"}); !cmd.Audit {
+		t.Fatal("Parse(audit) should set Audit")
+	}
+
+	if err := config.Save(&config.Config{Providers: map[string]map[string]interface{}{"glm": {}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(config.GetDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	output := captureStdout(t, func() {
+		if err := runAudit(); err != nil {
+			t.Errorf("runAudit() error = %v, want none", err)
+		}
+	})
+	if !strings.Contains(output, "-rw-------  "+config.GetConfigPath()) {
+		t.Errorf("output should list ccc.json with its mode, got:\n%s", output)
+	}
+
+	if err := os.Chmod(config.GetConfigPath(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output = captureStdout(t, func() {
+		err := runAudit()
+		if err == nil || !strings.Contains(err.Error(), "1 permission problem") {
+			t.Errorf("runAudit() error = %v, want 1 permission problem", err)
+		}
+	})
+	if !strings.Contains(output, "chmod 0600 "+config.GetConfigPath()) {
+		t.Errorf("output should suggest chmod 0600, got:\n%s", output)
+	}
+
+	// A readable settings.json without secrets is only a warning
+	if err := os.Chmod(config.GetConfigPath(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.GetSettingsPath(), []byte(`{"model": "opus"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output = captureStdout(t, func() {
+		if err := runAudit(); err != nil {
+			t.Errorf("runAudit() error = %v, want none", err)
+		}
+	})
+	if !strings.Contains(output, "(warning, it holds no secrets)") || !strings.Contains(output, "chmod 0600 "+config.GetSettingsPath()) {
+		t.Errorf("output should warn about settings.json, got:\n%s", output)
+	}
+}
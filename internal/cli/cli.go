@@ -2,6 +2,7 @@
 package cli
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -26,12 +27,28 @@ var BuildTime = "unknown"
 type Command struct {
 	Version      bool
 	Help         bool
+	Session      bool // --session flag, use a per-session settings file
 	Provider     string
 	ClaudeArgs   []string
 	Validate     bool
 	ValidateOpts *ValidateCommand
 	Patch        bool
 	PatchOpts    *PatchCommandOptions
+	ProviderCmd  bool
+	ProviderOpts *ProviderCommandOptions
+	ConfigCmd    bool
+	ConfigOpts   *ConfigCommandOptions
+	HistoryCmd   bool
+	HistoryOpts  *HistoryCommandOptions
+	Audit        bool
+	Schema       bool
+	SecretsCmd   bool
+	SecretsOpts  *SecretsCommandOptions
+	Migrate      bool
+	MigrateOpts  *MigrateCommandOptions
+	Explain      bool
+	ExplainOpts  *ExplainCommandOptions
+	Trust        bool
 }
 
 // ValidateCommand represents options for the validate command.
@@ -45,8 +62,52 @@ type PatchCommandOptions struct {
 	Reset bool // --reset flag, true means restore original claude
 }
 
+// cccSubcommands are ccc's own subcommands.
+var cccSubcommands = map[string]bool{
+	"validate": true,
+	"patch":    true,
+	"provider": true,
+	"history":  true,
+	"undo":     true,
+	"restore":  true,
+	"audit":    true,
+	"schema":   true,
+	"secrets":  true,
+	"migrate":  true,
+	"explain":  true,
+	"cfg":      true,
+	"trust":    true,
+}
+
+// claudeSubcommands are claude's own subcommands. After `ccc patch`, `claude mcp list`
+// reaches ccc as `mcp list`; these words are passed through to claude unchanged
+// instead of being taken as provider names.
+var claudeSubcommands = map[string]bool{
+	"config":            true,
+	"doctor":            true,
+	"install":           true,
+	"mcp":               true,
+	"migrate-installer": true,
+	"plugin":            true,
+	"setup-token":       true,
+	"update":            true,
+}
+
+// IsReservedName reports whether name is a ccc or claude subcommand.
+// A provider with such a name can only be selected with --provider.
+func IsReservedName(name string) bool {
+	return cccSubcommands[name] || claudeSubcommands[name]
+}
+
 // Parse parses command-line arguments.
 func Parse(args []string) *Command {
+	// --session 可以出现在 provider 之前，其余参数照常解析
+	if len(args) > 0 && args[0] == "--session" {
+		cmd := Parse(args[1:])
+		cmd.Session = true
+		return cmd
+	}
+
 	cmd := &Command{}
 	// 根据第一个参数判断是否是ccc的参数，其余参数透传给claude
 	firstArg := ""
@@ -57,12 +118,43 @@ func Parse(args []string) *Command {
 		cmd.Version = true
 	} else if firstArg == "--help" || firstArg == "-h" {
 		cmd.Help = true
+	} else if firstArg == "--provider" || strings.HasPrefix(firstArg, "--provider=") {
+		// 显式指定 provider，用于与子命令同名的 provider
+		parseExplicitProvider(cmd, args)
 	} else if firstArg == "validate" {
 		cmd.Validate = true
 		cmd.ValidateOpts = parseValidateArgs(args[1:])
 	} else if firstArg == "patch" {
 		cmd.Patch = true
 		cmd.PatchOpts = parsePatchArgs(args[1:])
+	} else if firstArg == "provider" {
+		cmd.ProviderCmd = true
+		cmd.ProviderOpts = parseProviderArgs(args[1:])
+	} else if firstArg == "cfg" {
+		// ccc cfg get/set/unset；config 属于 claude
+		cmd.ConfigCmd = true
+		cmd.ConfigOpts = parseConfigArgs(args[1:])
+	} else if firstArg == "history" || firstArg == "undo" || firstArg == "restore" {
+		cmd.HistoryCmd = true
+		cmd.HistoryOpts = &HistoryCommandOptions{Action: firstArg, Args: args[1:]}
+	} else if firstArg == "audit" {
+		cmd.Audit = true
+	} else if firstArg == "schema" {
+		cmd.Schema = true
+	} else if firstArg == "secrets" {
+		cmd.SecretsCmd = true
+		cmd.SecretsOpts = parseSecretsArgs(args[1:])
+	} else if firstArg == "migrate" {
+		cmd.Migrate = true
+		cmd.MigrateOpts = parseMigrateArgs(args[1:])
+	} else if firstArg == "explain" {
+		cmd.Explain = true
+		cmd.ExplainOpts = parseExplainArgs(args[1:])
+	} else if firstArg == "trust" {
+		cmd.Trust = true
+	} else if claudeSubcommands[firstArg] {
+		// claude 自身的子命令，原样透传
+		cmd.ClaudeArgs = args
 	} else if !strings.HasPrefix(firstArg, "-") {
 		cmd.Provider = firstArg
 		if len(args) > 1 {
@@ -74,6 +166,21 @@ func Parse(args []string) *Command {
 	return cmd
 }
 
+// parseExplicitProvider parses `--provider <name> [args...]` and
+// `--provider=<name> [args...]`. Everything after the name goes to claude.
+func parseExplicitProvider(cmd *Command, args []string) {
+	rest := args[1:]
+	if name, ok := strings.CutPrefix(args[0], "--provider="); ok {
+		cmd.Provider = name
+	} else if len(rest) > 0 {
+		cmd.Provider = rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		cmd.ClaudeArgs = rest
+	}
+}
+
 // parseValidateArgs parses arguments for the validate command.
 func parseValidateArgs(args []string) *ValidateCommand {
 	opts := &ValidateCommand{}
@@ -98,6 +205,28 @@ func parseValidateArgs(args []string) *ValidateCommand {
 	return opts
 }
 
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments. flag.FlagSet stops at the first positional argument,
+// so parsing is repeated on the remainder. Arguments after "--" are always
+// positional. Returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		// Everything after "--" is positional
+		if consumed := len(args) - fs.NArg(); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
 // parsePatchArgs parses arguments for the patch command.
 func parsePatchArgs(args []string) *PatchCommandOptions {
 	opts := &PatchCommandOptions{}
@@ -119,24 +248,61 @@ func parsePatchArgs(args []string) *PatchCommandOptions {
 // ShowHelp displays usage information.
 func ShowHelp(cfg *config.Config, cfgErr error) {
 	help := `Usage: ccc [provider] [args...]
+       ccc --session [provider] [args...]
+       ccc --provider <name> [args...]
        ccc validate [provider] [--all]
        ccc patch [--reset]
+       ccc provider add|remove|rename|copy ...
+       ccc cfg get|set|unset <path> [value]
+       ccc history config | ccc undo | ccc restore <id>
+       ccc audit
+       ccc schema
+       ccc secrets split|encrypt|unlock
+       ccc migrate [--dry-run]
+       ccc explain [provider] [key.path] [--json]
+       ccc trust
 
 Claude Code Configuration Switcher
 
 Commands:
-  ccc                    Use the current provider (or the first provider if none is set)
+  ccc                    Use the current provider (or the default provider if none is set)
   ccc <provider>         Switch to the specified provider and run Claude Code
+  ccc --session <provider>        Run with a private settings file, leaving settings.json untouched
+  ccc --provider <name>  Use a provider whose name collides with a subcommand
+  ccc mcp|config|update|doctor|...  Claude subcommands are passed through to claude
   ccc validate           Validate the current provider configuration
   ccc validate <provider>         Validate a specific provider configuration
   ccc validate --all              Validate all provider configurations
   ccc patch               Replace claude command with ccc (requires sudo)
   ccc patch --reset       Restore original claude command (requires sudo)
+  ccc provider add <name> [--base-url URL] [--token TOKEN] [--model MODEL]
+                          Add a provider to ccc.json
+  ccc provider remove <name>      Remove a provider
+  ccc provider rename <old> <new> Rename a provider
+  ccc provider copy <src> <dst>   Copy a provider under a new name
+  ccc cfg get <path>              Print a value from ccc.json (secrets masked)
+  ccc cfg set <path> <value>      Set a value, e.g. providers.glm.env.ANTHROPIC_MODEL
+  ccc cfg unset <path>            Remove a value
+                          (ccc config ... runs claude config ... instead)
+  ccc history config              List snapshots of settings.json and ccc.json with diffs
+  ccc undo                        Undo the latest change to settings.json or ccc.json
+  ccc restore <id>                Restore the files of a snapshot
+  ccc audit                       Check the permissions of the config directory and files
+  ccc schema                      Print the JSON Schema of ccc.json
+  ccc secrets split               Move tokens and keys from ccc.json to ccc.secrets.json
+  ccc secrets encrypt             Encrypt tokens and keys with a passphrase
+  eval "$(ccc secrets unlock)"    Keep the passphrase in this shell ($CCC_PASSPHRASE)
+  ccc migrate [--dry-run]         Upgrade ccc.json to the current format (done automatically)
+  ccc explain [provider] [key.path]
+                          Show the settings and env a provider runs with, and where each value comes from
+  ccc trust                       Apply the settings and claude_args of this directory's .ccc.json
   ccc --help             Show this help message
   ccc --version          Show version information
 
 Environment Variables:
   CCC_CONFIG_DIR         Override the configuration directory (default: ~/.claude/)
+  CCC_SESSION            Set to 1 to always launch in --session mode
+  CCC_PASSPHRASE         Passphrase of encrypted secrets (asked for if not set)
 `
 	fmt.Print(help)
 
@@ -151,7 +317,7 @@ Environment Variables:
 		// Display provider list from config
 		if cfg != nil && len(cfg.Providers) > 0 {
 			fmt.Println("\nAvailable Providers:")
-			for name := range cfg.Providers {
+			for _, name := range config.ProviderNames(cfg) {
 				marker := ""
 				if name == cfg.CurrentProvider {
 					marker = " (current)"
@@ -175,6 +341,47 @@ func Run(cmd *Command) error {
 		return RunPatch(cmd.PatchOpts)
 	}
 
+	// Handle provider subcommand (loads and saves ccc.json itself)
+	if cmd.ProviderCmd {
+		return runProvider(cmd.ProviderOpts)
+	}
+
+	// Handle config subcommand (loads and saves ccc.json itself)
+	if cmd.ConfigCmd {
+		return runConfig(cmd.ConfigOpts)
+	}
+
+	// Handle history, undo and restore (read and write the files themselves)
+	if cmd.HistoryCmd {
+		return runHistory(cmd.HistoryOpts)
+	}
+
+	// Handle audit (only inspects the files)
+	if cmd.Audit {
+		return runAudit()
+	}
+
+	// Handle trust (only records the project file)
+	if cmd.Trust {
+		return runTrust()
+	}
+
+	// Handle secrets subcommand (loads and saves ccc.json itself)
+	if cmd.SecretsCmd {
+		return runSecrets(cmd.SecretsOpts)
+	}
+
+	// Handle migrate subcommand (loads and saves ccc.json itself)
+	if cmd.Migrate {
+		return runMigrate(cmd.MigrateOpts)
+	}
+
+	// Handle schema
+	if cmd.Schema {
+		fmt.Println(string(config.Schema()))
+		return nil
+	}
+
 	// Handle --version
 	if cmd.Version {
 		ShowVersion()
@@ -208,10 +415,37 @@ func Run(cmd *Command) error {
 		}
 	}
 
+	// Bring an older ccc.json up to date once, before it is used
+	if len(migration.Pending(cfg)) > 0 {
+		if cfg, err = migrateOnLoad(); err != nil {
+			return err
+		}
+	}
+
+	warnConfigPermissions()
+
+	// Pick up the project file of the current directory tree, if any
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	if cfg.Project, err = config.FindProjectConfig(cwd); err != nil {
+		return err
+	}
+	if cfg.Project != nil {
+		if cfg.Project, err = checkProjectTrust(cfg.Project); err != nil {
+			return err
+		}
+	}
+
 	if cmd.Validate {
 		return runValidate(cfg, cmd.ValidateOpts)
 	}
 
+	if cmd.Explain {
+		return runExplain(cfg, cmd.ExplainOpts)
+	}
+
 	// Run claude with the provider (provider determination is inside runClaude)
 	return runClaude(cfg, cmd)
 }
@@ -258,8 +492,8 @@ func checkValidateEnvConflict(cfg *config.Config, opts *ValidateCommand) error {
 
 	providerNames := validateTargetProviders(cfg, opts)
 	for _, name := range providerNames {
-		providerSettings, ok := cfg.Providers[name]
-		if !ok {
+		providerSettings, err := config.ResolveProvider(cfg, name)
+		if err != nil {
 			continue
 		}
 		for key := range config.GetEnv(providerSettings) {
@@ -284,11 +518,7 @@ func checkValidateEnvConflict(cfg *config.Config, opts *ValidateCommand) error {
 // not provided and there's no current provider), it returns every configured provider.
 func validateTargetProviders(cfg *config.Config, opts *ValidateCommand) []string {
 	if opts.ValidateAll {
-		names := make([]string, 0, len(cfg.Providers))
-		for name := range cfg.Providers {
-			names = append(names, name)
-		}
-		return names
+		return config.ProviderNames(cfg)
 	}
 
 	name := opts.Provider
@@ -297,11 +527,7 @@ func validateTargetProviders(cfg *config.Config, opts *ValidateCommand) []string
 	}
 	if name == "" {
 		// No target identifiable: treat as --all to stay strict.
-		names := make([]string, 0, len(cfg.Providers))
-		for n := range cfg.Providers {
-			names = append(names, n)
-		}
-		return names
+		return config.ProviderNames(cfg)
 	}
 	return []string{name}
 }
@@ -309,6 +535,15 @@ func validateTargetProviders(cfg *config.Config, opts *ValidateCommand) []string
 // configAdapter adapts config.Config to the validate.Config interface.
 type configAdapter struct {
 	cfg *config.Config
+	// resolved caches env values by their raw value, so a reference shared by
+	// several providers is resolved (and prompts) only once
+	resolved map[string]resolvedValue
+}
+
+// resolvedValue is a resolved env value, or why it could not be resolved.
+type resolvedValue struct {
+	value   string
+	problem string
 }
 
 func (a *configAdapter) Providers() map[string]map[string]interface{} {
@@ -319,6 +554,71 @@ func (a *configAdapter) CurrentProvider() string {
 	return a.cfg.CurrentProvider
 }
 
+// ProviderNames returns the providers in display order,
+// implementing validate.ProviderLister.
+func (a *configAdapter) ProviderNames() []string {
+	return config.ProviderNames(a.cfg)
+}
+
+// ResolveProvider returns the settings a provider is launched with: its
+// extends chain and the project file resolved, and under env the variables
+// of the claude process (base env included), implementing
+// validate.ProviderResolver.
+func (a *configAdapter) ResolveProvider(name string) (map[string]interface{}, error) {
+	settings, err := provider.ResolveSettings(a.cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make(map[string]interface{}, len(settings)+1)
+	for k, v := range settings {
+		resolved[k] = v
+	}
+	resolved["env"] = provider.LaunchEnv(a.cfg, settings)
+	return resolved, nil
+}
+
+// ResolveEnv resolves ${VAR} and secret references in a provider env,
+// implementing validate.EnvResolver. Each distinct value is resolved once.
+func (a *configAdapter) ResolveEnv(env map[string]interface{}) (map[string]interface{}, map[string]string) {
+	if a.resolved == nil {
+		a.resolved = make(map[string]resolvedValue)
+	}
+
+	result := make(map[string]interface{}, len(env))
+	var problems map[string]string
+	for key, v := range env {
+		// A null unsets the variable
+		if v == nil {
+			continue
+		}
+		raw := fmt.Sprintf("%v", v)
+		value, cached := a.resolved[raw]
+		if !cached {
+			resolved, err := config.ResolveEnv(map[string]interface{}{key: v})
+			var envErr *config.EnvError
+			switch {
+			case errors.As(err, &envErr):
+				value.problem = envErr.Problems[key]
+			case err != nil:
+				value.problem = err.Error()
+			default:
+				value.value = resolved[key]
+			}
+			a.resolved[raw] = value
+		}
+
+		if value.problem != "" {
+			if problems == nil {
+				problems = make(map[string]string)
+			}
+			problems[key] = value.problem
+			continue
+		}
+		result[key] = value.value
+	}
+	return result, problems
+}
+
 // Execute is the main entry point for the CLI.
 func Execute() error {
 	cmd := Parse(os.Args[1:])
@@ -1,10 +1,16 @@
 package cli
 
 import (
+	"encoding/json"
+	"io"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/validate"
 )
 
 func setupTestDir(t *testing.T) func() {
@@ -29,12 +35,46 @@ func setupTestDir(t *testing.T) func() {
 	return cleanup
 }
 
+// captureStdout returns everything fn writes to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureStderr returns everything fn writes to stderr.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+// captureFile returns everything fn writes to *file, e.g. os.Stdout.
+func captureFile(t *testing.T, file **os.File, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *file
+	*file = w
+	defer func() { *file = original }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
 func TestParse(t *testing.T) {
 	tests := []struct {
 		name             string
 		args             []string
 		wantVersion      bool
 		wantHelp         bool
+		wantSession      bool
 		wantValidate     bool
 		wantValidateAll  bool
 		wantValidateProv string
@@ -91,6 +131,37 @@ func TestParse(t *testing.T) {
 			wantProvider:   "",
 			wantClaudeArgs: []string{"--debug"},
 		},
+		{
+			name:           "--session with provider",
+			args:           []string{"--session", "kimi", "-p"},
+			wantSession:    true,
+			wantProvider:   "kimi",
+			wantClaudeArgs: []string{"-p"},
+		},
+		{
+			name:           "claude subcommand passed through",
+			args:           []string{"mcp", "list"},
+			wantProvider:   "",
+			wantClaudeArgs: []string{"mcp", "list"},
+		},
+		{
+			name:           "claude doctor passed through",
+			args:           []string{"doctor"},
+			wantProvider:   "",
+			wantClaudeArgs: []string{"doctor"},
+		},
+		{
+			name:           "--provider selects provider named like a subcommand",
+			args:           []string{"--provider", "mcp", "-p"},
+			wantProvider:   "mcp",
+			wantClaudeArgs: []string{"-p"},
+		},
+		{
+			name:           "--provider=name form",
+			args:           []string{"--provider=validate", "mcp", "list"},
+			wantProvider:   "validate",
+			wantClaudeArgs: []string{"mcp", "list"},
+		},
 		{
 			name:         "validate command",
 			args:         []string{"validate"},
@@ -120,6 +191,9 @@ func TestParse(t *testing.T) {
 			if cmd.Help != tt.wantHelp {
 				t.Errorf("Help = %v, want %v", cmd.Help, tt.wantHelp)
 			}
+			if cmd.Session != tt.wantSession {
+				t.Errorf("Session = %v, want %v", cmd.Session, tt.wantSession)
+			}
 			if cmd.Validate != tt.wantValidate {
 				t.Errorf("Validate = %v, want %v", cmd.Validate, tt.wantValidate)
 			}
@@ -136,11 +210,49 @@ func TestParse(t *testing.T) {
 			}
 			if len(cmd.ClaudeArgs) != len(tt.wantClaudeArgs) {
 				t.Errorf("ClaudeArgs length = %d, want %d", len(cmd.ClaudeArgs), len(tt.wantClaudeArgs))
+			} else {
+				for i := range cmd.ClaudeArgs {
+					if cmd.ClaudeArgs[i] != tt.wantClaudeArgs[i] {
+						t.Errorf("ClaudeArgs[%d] = %q, want %q", i, cmd.ClaudeArgs[i], tt.wantClaudeArgs[i])
+					}
+				}
 			}
 		})
 	}
 }
 
+func TestIsReservedName(t *testing.T) {
+	for _, name := range []string{"validate", "patch", "audit", "schema", "cfg", "mcp", "config", "update", "doctor"} {
+		if !IsReservedName(name) {
+			t.Errorf("IsReservedName(%q) = false, want true", name)
+		}
+	}
+	for _, name := range []string{"glm", "kimi", ""} {
+		if IsReservedName(name) {
+			t.Errorf("IsReservedName(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestRunSchema(t *testing.T) {
+	cmd := Parse([]string{"schema"})
+	if !cmd.Schema {
+		t.Fatal("Parse(schema) should set Schema")
+	}
+	output := captureStdout(t, func() {
+		if err := Run(cmd); err != nil {
+			t.Errorf("Run(schema) error = %v", err)
+		}
+	})
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &schema); err != nil {
+		t.Fatalf("schema output is not JSON: %v", err)
+	}
+	if schema["title"] != "ccc.json" {
+		t.Errorf("schema title = %v, want ccc.json", schema["title"])
+	}
+}
+
 func TestShowVersion(t *testing.T) {
 	// Capture stdout
 	old := Version
@@ -241,9 +353,13 @@ func TestDetermineProvider(t *testing.T) {
 		}
 		cmd := &Command{Provider: ""}
 		got := determineProvider(cmd, cfg)
-		// Since map iteration order is random, just check it's one of the valid providers
-		if got != "kimi" && got != "glm" {
-			t.Errorf("determineProvider() = %q, want kimi or glm", got)
+		if got != "glm" {
+			t.Errorf("determineProvider() = %q, want glm (first in sorted order)", got)
+		}
+
+		cfg.DefaultProvider = "kimi"
+		if got := determineProvider(cmd, cfg); got != "kimi" {
+			t.Errorf("determineProvider() = %q, want default_provider kimi", got)
 		}
 	})
 
@@ -274,6 +390,84 @@ func TestDetermineProvider(t *testing.T) {
 			t.Errorf("determineProvider() = %q, want empty string", got)
 		}
 	})
+
+	t.Run("project pin", func(t *testing.T) {
+		cfg := &config.Config{
+			CurrentProvider: "kimi",
+			Providers: map[string]map[string]interface{}{
+				"kimi":     {},
+				"glm":      {},
+				"internal": {},
+			},
+			Project: &config.ProjectConfig{Provider: "internal", Path: "/repo/.ccc.json"},
+		}
+
+		tests := []struct {
+			name     string
+			provider string
+			want     string
+		}{
+			{"pin beats current_provider", "", "internal"},
+			{"command line beats pin", "glm", "glm"},
+			{"unknown command line falls back to pin", "unknown", "internal"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got := determineProvider(&Command{Provider: tt.provider}, cfg)
+				if got != tt.want {
+					t.Errorf("determineProvider() = %q, want %q", got, tt.want)
+				}
+			})
+		}
+
+		// A pin without a provider leaves the usual order in place
+		cfg.Project = &config.ProjectConfig{Path: "/repo/.ccc.json"}
+		if got := determineProvider(&Command{}, cfg); got != "kimi" {
+			t.Errorf("determineProvider() = %q, want kimi", got)
+		}
+	})
+
+	t.Run("verbose prints the lookup order", func(t *testing.T) {
+		cfg := &config.Config{
+			CurrentProvider: "kimi",
+			Providers: map[string]map[string]interface{}{
+				"kimi":     {},
+				"internal": {},
+			},
+			Project: &config.ProjectConfig{
+				Provider:   "internal",
+				ClaudeArgs: []string{"--verbose"},
+				Path:       "/repo/.ccc.json",
+			},
+		}
+
+		var output string
+		stdout := captureStdout(t, func() {
+			output = captureStderr(t, func() {
+				determineProvider(&Command{}, cfg)
+			})
+		})
+		if stdout != "" {
+			t.Errorf("lookup should go to stderr, got on stdout:\n%s", stdout)
+		}
+		for _, want := range []string{
+			"1. command line: -",
+			"2. project /repo/.ccc.json: internal  <- selected",
+			"3. current_provider: kimi",
+		} {
+			if !strings.Contains(output, want) {
+				t.Errorf("output should contain %q, got:\n%s", want, output)
+			}
+		}
+
+		cfg.Project.ClaudeArgs = nil
+		output = captureStderr(t, func() {
+			determineProvider(&Command{}, cfg)
+		})
+		if output != "" {
+			t.Errorf("lookup should only be printed with --verbose, got:\n%s", output)
+		}
+	})
 }
 
 func TestRun(t *testing.T) {
@@ -371,3 +565,67 @@ func TestParseValidateArgs(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigAdapterValidatesLaunchEnv(t *testing.T) {
+	t.Setenv("CCC_TEST_UNSET_TOKEN", "")
+	os.Unsetenv("CCC_TEST_UNSET_TOKEN")
+
+	cfg := &config.Config{
+		Settings: map[string]interface{}{
+			"env": map[string]interface{}{"API_TIMEOUT": "${CCC_TEST_UNSET_TOKEN}"},
+		},
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{
+				"ANTHROPIC_BASE_URL":   "https://open.bigmodel.cn/api/anthropic",
+				"ANTHROPIC_AUTH_TOKEN": "sk-glm",
+			}},
+		},
+		Project: &config.ProjectConfig{
+			Path: "/work/.ccc.json",
+			Settings: map[string]interface{}{
+				"env": map[string]interface{}{"ANTHROPIC_BASE_URL": "ftp://example.com"},
+			},
+		},
+	}
+
+	// The base env and the project env are checked like the launch resolves them
+	result := validate.ValidateProvider(&configAdapter{cfg: cfg}, "glm")
+	want := []string{
+		"Cannot resolve API_TIMEOUT: ${CCC_TEST_UNSET_TOKEN} is not set",
+		"Invalid Base URL format: must use http:// or https:// scheme",
+	}
+	if result.Valid || !reflect.DeepEqual(result.Errors, want) {
+		t.Errorf("ValidateProvider() errors = %q, want %q", result.Errors, want)
+	}
+}
+
+func TestConfigAdapterResolvesEachValueOnce(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	token := "cmd:echo run >> " + counter + "; echo sk-shared"
+	cfg := &config.Config{
+		Providers: map[string]map[string]interface{}{
+			"glm":  {"env": map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": token}},
+			"kimi": {"env": map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": token, "API_KEY": "${CCC_TEST_UNSET_TOKEN}"}},
+		},
+	}
+	os.Unsetenv("CCC_TEST_UNSET_TOKEN")
+
+	adapter := &configAdapter{cfg: cfg}
+	for _, name := range []string{"glm", "kimi"} {
+		resolved, problems := adapter.ResolveEnv(config.GetEnv(cfg.Providers[name]))
+		if resolved["ANTHROPIC_AUTH_TOKEN"] != "sk-shared" {
+			t.Errorf("%s: ANTHROPIC_AUTH_TOKEN = %v, want sk-shared", name, resolved["ANTHROPIC_AUTH_TOKEN"])
+		}
+		if name == "kimi" && problems["API_KEY"] == "" {
+			t.Errorf("%s: problems = %v, want API_KEY", name, problems)
+		}
+	}
+
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Errorf("command ran %d times, want once", n)
+	}
+}
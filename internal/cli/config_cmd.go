@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// ConfigCommandOptions represents options for the cfg command.
+type ConfigCommandOptions struct {
+	Action string   // get, set or unset
+	Args   []string // positional arguments after the action
+	Type   string   // --type for set: string, number, bool or json
+	Reveal bool     // --reveal for get: print secrets unmasked
+}
+
+// configUsage is shown when the cfg command is used incorrectly.
+const configUsage = `usage: ccc cfg get <path> [--reveal]
+       ccc cfg set <path> <value> [--type string|number|bool|json]
+       ccc cfg unset <path>`
+
+// parseConfigArgs parses arguments for the cfg command.
+// Flags may appear before or after the positional arguments.
+func parseConfigArgs(args []string) *ConfigCommandOptions {
+	opts := &ConfigCommandOptions{}
+	if len(args) == 0 {
+		return opts
+	}
+	opts.Action = args[0]
+
+	fs := flag.NewFlagSet("cfg", flag.ContinueOnError)
+	fs.Usage = func() {} // Suppress default usage output
+	fs.StringVar(&opts.Type, "type", "", "value type for set")
+	fs.BoolVar(&opts.Reveal, "reveal", false, "print secrets unmasked")
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		// On parse error, return options without an action
+		return &ConfigCommandOptions{}
+	}
+	opts.Args = positional
+
+	return opts
+}
+
+// runConfig executes the cfg command on the dynamic tree of ccc.json.
+func runConfig(opts *ConfigCommandOptions) error {
+	wantArgs := map[string]int{"get": 1, "set": 2, "unset": 1}
+	if n, ok := wantArgs[opts.Action]; !ok || len(opts.Args) != n {
+		return fmt.Errorf("%s", configUsage)
+	}
+
+	path, err := config.SplitPath(opts.Args[0])
+	if err != nil {
+		return err
+	}
+
+	// set and unset rewrite ccc.json; keep other ccc processes out meanwhile
+	if opts.Action != "get" {
+		unlock, err := config.Lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	tree, err := config.ToMap(cfg)
+	if err != nil {
+		return err
+	}
+
+	if opts.Action == "get" {
+		value, ok := config.GetPath(tree, path)
+		if !ok {
+			return fmt.Errorf("'%s' is not set", opts.Args[0])
+		}
+		if !opts.Reveal {
+			value = config.MaskSecrets(path[len(path)-1], value)
+		}
+		return printConfigValue(value)
+	}
+
+	switch opts.Action {
+	case "set":
+		existing, exists := config.GetPath(tree, path)
+		value, err := config.ParseValue(opts.Args[1], opts.Type, path, existing, exists)
+		if err != nil {
+			return err
+		}
+		if err := config.SetPath(tree, path, value); err != nil {
+			return err
+		}
+	case "unset":
+		if !config.UnsetPath(tree, path) {
+			return fmt.Errorf("'%s' is not set", opts.Args[0])
+		}
+	}
+
+	// Converting back validates key names and value types before anything is written
+	updated, err := config.FromMap(tree)
+	if err != nil {
+		return fmt.Errorf("refusing to save ccc.json: %w", err)
+	}
+	updated.Fragments = cfg.Fragments
+	updated.Secrets = cfg.Secrets
+	if err := config.WithSnapshot(fmt.Sprintf("cfg %s %s", opts.Action, opts.Args[0]), func() error {
+		return config.Save(updated)
+	}); err != nil {
+		return err
+	}
+
+	if opts.Action == "set" {
+		fmt.Printf("Set %s\n", opts.Args[0])
+	} else {
+		fmt.Printf("Unset %s\n", opts.Args[0])
+	}
+	return nil
+}
+
+// printConfigValue prints strings as-is and everything else as JSON.
+func printConfigValue(value interface{}) error {
+	if s, ok := value.(string); ok {
+		fmt.Println(s)
+		return nil
+	}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to format value: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestParseConfigArgs(t *testing.T) {
+	got := parseConfigArgs([]string{"set", "settings.cleanupPeriodDays", "30", "--type", "number"})
+	want := &ConfigCommandOptions{Action: "set", Args: []string{"settings.cleanupPeriodDays", "30"}, Type: "number"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfigArgs() = %+v, want %+v", got, want)
+	}
+
+	// Values that look like flags can follow "--"
+	got = parseConfigArgs([]string{"set", "settings.x", "--", "-5"})
+	want = &ConfigCommandOptions{Action: "set", Args: []string{"settings.x", "-5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfigArgs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseConfigPassthrough(t *testing.T) {
+	cmd := Parse([]string{"cfg", "get", "current_provider"})
+	if !cmd.ConfigCmd {
+		t.Error("`cfg get` should be handled by ccc")
+	}
+
+	// After `ccc patch`, `claude config get|set` reaches ccc and belongs to claude
+	for _, args := range [][]string{{"config", "get", "theme"}, {"config", "set", "theme", "dark"}, {"config", "list"}} {
+		cmd = Parse(args)
+		if cmd.ConfigCmd || len(cmd.ClaudeArgs) != len(args) {
+			t.Errorf("Parse(%q) should pass through to claude, got %+v", args, cmd)
+		}
+	}
+}
+
+func TestRunConfig(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := config.Save(&config.Config{
+		CurrentProvider: "glm",
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.7"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) error {
+		return runConfig(parseConfigArgs(args))
+	}
+
+	if err := run("set", "providers.glm.env.ANTHROPIC_MODEL", "glm-4.5-air"); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := run("set", "providers.glm.env.API_TIMEOUT", "30000"); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := run("set", "settings.alwaysThinkingEnabled", "true"); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := run("get", "providers.glm.env"); err != nil {
+		t.Fatalf("get error = %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if config.GetModel(cfg.Providers["glm"]) != "glm-4.5-air" {
+		t.Errorf("model = %q, want glm-4.5-air", config.GetModel(cfg.Providers["glm"]))
+	}
+	if config.GetEnv(cfg.Providers["glm"])["API_TIMEOUT"] != "30000" {
+		t.Error("env values should be stored as strings")
+	}
+	if cfg.Settings["alwaysThinkingEnabled"] != true {
+		t.Error("settings.alwaysThinkingEnabled should be a bool")
+	}
+
+	if err := run("unset", "providers.glm.env.API_TIMEOUT"); err != nil {
+		t.Fatalf("unset error = %v", err)
+	}
+	if err := run("get", "providers.glm.env.API_TIMEOUT"); err == nil {
+		t.Error("get of an unset value should error")
+	}
+
+	// A null is written as a deletion marker of the base settings
+	if err := run("set", "settings.model", "opus"); err != nil {
+		t.Fatalf("set error = %v", err)
+	}
+	if err := run("set", "providers.glm.model", "null"); err != nil {
+		t.Fatalf("set null error = %v", err)
+	}
+	if err := run("get", "providers.glm.model"); err != nil {
+		t.Errorf("get of a null value error = %v", err)
+	}
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if value, exists := cfg.Providers["glm"]["model"]; !exists || value != nil {
+		t.Errorf("providers.glm.model = %v (exists %v), want null", value, exists)
+	}
+	if merged := config.MergeWithPriority(cfg.Settings, cfg.Providers["glm"], nil, nil); merged["model"] != nil {
+		t.Errorf("merged model = %v, want deleted by the null", merged["model"])
+	}
+
+	// Edits that would break ccc.json are refused
+	err = run("set", "claude_args", "--", "--verbose")
+	if err == nil || !strings.Contains(err.Error(), "refusing to save") {
+		t.Errorf("set claude_args to string error = %v, want refusal", err)
+	}
+	err = run("set", "provider", "glm")
+	if err == nil || !strings.Contains(err.Error(), "unknown config key") {
+		t.Errorf("set unknown key error = %v, want refusal", err)
+	}
+
+	if err := run("set", "a"); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("set without value error = %v, want usage", err)
+	}
+}
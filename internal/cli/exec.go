@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
 	"syscall"
 
@@ -11,12 +13,67 @@ import (
 	"github.com/guyskk/ccc/internal/provider"
 )
 
+// ExitError reports that claude exited with a non-zero status while ccc was
+// waiting for it (session mode). The caller should exit with the same code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("claude exited with status %d", e.Code)
+}
+
 // executeProcess replaces the current process with the specified command.
 // This uses syscall.Exec which does not return on success.
 func executeProcess(path string, args []string, env []string) error {
 	return syscall.Exec(path, args, env)
 }
 
+// runProcess runs the specified command as a child process and waits for it,
+// so the caller can clean up afterwards. Terminal signals (SIGINT) reach the
+// child directly through the process group; SIGTERM and SIGHUP sent to ccc are
+// forwarded. Returns the child's exit code.
+func runProcess(path string, args []string, env []string) (int, error) {
+	c := exec.Command(path, args[1:]...)
+	c.Args = args
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	if err := c.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start claude: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				if sig != syscall.SIGINT {
+					_ = c.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	if err := c.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return 0, fmt.Errorf("failed to wait for claude: %w", err)
+	}
+	return 0, nil
+}
+
 // checkSettingsEnvConflict refuses to start claude when settings.json's env field
 // contains keys that would silently override the provider env ccc passes to claude.
 // See docs/discuss-20260519-env-priority.md for the empirical proof that settings.json
@@ -26,8 +83,8 @@ func executeProcess(path string, args []string, env []string) error {
 // provider's env. A non-nil error means the user must clean settings.json before ccc
 // will launch claude — ccc never modifies the user's settings.json on their behalf.
 func checkSettingsEnvConflict(cfg *config.Config, providerName string) error {
-	providerSettings, ok := cfg.Providers[providerName]
-	if !ok {
+	providerSettings, err := provider.ResolveSettings(cfg, providerName)
+	if err != nil {
 		// Provider lookup failure is surfaced later by SwitchWithHook; we just skip the guard.
 		return nil
 	}
@@ -60,33 +117,110 @@ func checkSettingsEnvConflict(cfg *config.Config, providerName string) error {
 	))
 }
 
-// determineProvider determines which provider to use based on the command and config.
+// providerStep is one step of the provider lookup order.
+type providerStep struct {
+	source string // where the provider name comes from
+	name   string // empty if this source names no provider
+}
+
+// determineProvider determines which provider to use. The lookup order is:
+//  1. the provider given on the command line
+//  2. the provider pinned by the project file (.ccc.json)
+//  3. current_provider in ccc.json
+//  4. default_provider in ccc.json, or else the first provider in display order
+//
+// A provider given on the command line that doesn't exist falls back to
+// steps 2 and 3 only. With --verbose in the claude args, every step is printed
+// to stderr, apart from the output of ccc and claude.
 func determineProvider(cmd *Command, cfg *config.Config) string {
-	if cmd.Provider != "" {
-		// User specified a provider, check if it's valid
-		if _, exists := cfg.Providers[cmd.Provider]; exists {
-			return cmd.Provider
-		}
-		// Not a valid provider, try using current provider
-		if cfg.CurrentProvider != "" {
-			fmt.Printf("Unknown provider: %s\n", cmd.Provider)
-			fmt.Printf("Using current provider: %s\n", cfg.CurrentProvider)
-			return cfg.CurrentProvider
+	steps, selected, unknown := lookupProvider(cmd, cfg)
+
+	if isVerbose(cmd, cfg) {
+		fmt.Fprintln(os.Stderr, "Provider lookup:")
+		for i, step := range steps {
+			name := step.name
+			if name == "" {
+				name = "-"
+			}
+			switch {
+			case i == selected:
+				name += "  <- selected"
+			case i == 0 && unknown:
+				name += "  (unknown provider)"
+			}
+			fmt.Fprintf(os.Stderr, "  %d. %s: %s\n", i+1, step.source, name)
 		}
+	}
+
+	if selected < 0 {
 		return ""
 	}
+	if unknown {
+		fmt.Printf("Unknown provider: %s\n", cmd.Provider)
+		fmt.Printf("Using %s provider: %s\n", fallbackLabel(selected), steps[selected].name)
+	}
+	return steps[selected].name
+}
+
+// lookupProvider runs the lookup of determineProvider without printing
+// anything. Returns the steps, the index of the selected one (-1 if none)
+// and whether the provider given on the command line is unknown.
+func lookupProvider(cmd *Command, cfg *config.Config) ([]providerStep, int, bool) {
+	steps := []providerStep{{source: "command line", name: cmd.Provider}}
+	if cfg.Project != nil {
+		steps = append(steps, providerStep{source: "project " + cfg.Project.Path, name: cfg.Project.Provider})
+	} else {
+		steps = append(steps, providerStep{source: "project file (" + config.ProjectFileName + " not found)"})
+	}
+	steps = append(steps,
+		providerStep{source: "current_provider", name: cfg.CurrentProvider},
+		providerStep{source: "default provider", name: provider.GetDefaultProvider(cfg)},
+	)
 
-	// No provider specified, use current or first available
-	if cfg.CurrentProvider != "" {
-		return cfg.CurrentProvider
+	selected := -1
+	unknown := false
+	for i, step := range steps {
+		if step.name == "" {
+			continue
+		}
+		if i == 0 {
+			if _, exists := cfg.Providers[step.name]; !exists {
+				// Not a valid provider, fall back to the pinned or current provider
+				unknown = true
+				continue
+			}
+		}
+		// An unknown command-line provider never falls back to the default one
+		if unknown && i == len(steps)-1 {
+			break
+		}
+		selected = i
+		break
 	}
+	return steps, selected, unknown
+}
 
-	// Use the first available provider
-	for name := range cfg.Providers {
-		return name
+// fallbackLabel names the lookup step used instead of an unknown provider.
+func fallbackLabel(step int) string {
+	if step == 1 {
+		return "project"
 	}
+	return "current"
+}
 
-	return ""
+// isVerbose reports whether --verbose is among the claude args from any source.
+func isVerbose(cmd *Command, cfg *config.Config) bool {
+	args := append([]string{}, cfg.ClaudeArgs...)
+	if cfg.Project != nil {
+		args = append(args, cfg.Project.ClaudeArgs...)
+	}
+	args = append(args, cmd.ClaudeArgs...)
+	for _, arg := range args {
+		if arg == "--verbose" {
+			return true
+		}
+	}
+	return false
 }
 
 // runClaude executes the claude command for the given provider.
@@ -106,11 +240,25 @@ func runClaude(cfg *config.Config, cmd *Command) error {
 	if err := checkSettingsEnvConflict(cfg, providerName); err != nil {
 		return err
 	}
-
-	// Switch provider and clean up supervisor hooks
-	result, err := provider.SwitchWithHook(cfg, providerName)
+	providerArgs, err := config.ProviderClaudeArgs(cfg, providerName)
 	if err != nil {
-		return fmt.Errorf("error switching provider: %w", err)
+		return err
+	}
+
+	// In session mode the merged settings go to a private file passed via
+	// --settings; otherwise switch provider
+	var result *provider.SwitchResult
+	if cmd.Session || os.Getenv("CCC_SESSION") == "1" {
+		result, err = provider.PrepareSession(cfg, providerName)
+		if err != nil {
+			return fmt.Errorf("error preparing session: %w", err)
+		}
+		defer os.Remove(result.SettingsPath)
+	} else {
+		result, err = provider.SwitchWithHook(cfg, providerName)
+		if err != nil {
+			return fmt.Errorf("error switching provider: %w", err)
+		}
 	}
 	fmt.Printf("Launching with provider: %s\n", providerName)
 
@@ -134,10 +282,14 @@ func runClaude(cfg *config.Config, cmd *Command) error {
 
 	// Build arguments (argv[0] must be the program name)
 	execArgs := []string{"claude"}
-	if len(cfg.ClaudeArgs) > 0 {
-		execArgs = append(execArgs, cfg.ClaudeArgs...)
+	if result.SettingsPath != "" {
+		execArgs = append(execArgs, "--settings", result.SettingsPath)
+	}
+	var projectArgs []string
+	if cfg.Project != nil {
+		projectArgs = cfg.Project.ClaudeArgs
 	}
-	execArgs = append(execArgs, cmd.ClaudeArgs...)
+	execArgs = append(execArgs, config.CombineClaudeArgs(cfg.ClaudeArgs, providerArgs, projectArgs, cmd.ClaudeArgs)...)
 
 	// Build environment variables
 	// Start with current process environment
@@ -146,6 +298,10 @@ func runClaude(cfg *config.Config, cmd *Command) error {
 	// Remove existing environment variables to ensure provider config takes precedence
 	prefixes := []string{"CLAUDE_", "ANTHROPIC_"}
 	env = filterEnvVars(env, func(key string) bool {
+		// The passphrase of encrypted secrets is for ccc only
+		if key == config.PassphraseEnv {
+			return false
+		}
 		for _, prefix := range prefixes {
 			if strings.HasPrefix(key, prefix) {
 				return false
@@ -160,6 +316,18 @@ func runClaude(cfg *config.Config, cmd *Command) error {
 		env = append(env, envPairs...)
 	}
 
+	// Session mode: wait for claude so the session settings file can be removed
+	if result.SettingsPath != "" {
+		code, err := runProcess(claudePath, execArgs, env)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return &ExitError{Code: code}
+		}
+		return nil
+	}
+
 	// Execute the process (replaces current process, does not return on success)
 	return executeProcess(claudePath, execArgs, env)
 }
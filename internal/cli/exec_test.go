@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -123,6 +124,33 @@ func TestCheckSettingsEnvConflict_ManagedHit(t *testing.T) {
 	}
 }
 
+func TestCheckSettingsEnvConflict_InheritedKey(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeSettingsJSON(t, `{"env":{"HTTP_PROXY":"http://localhost:8080"}}`)
+
+	cfg := &config.Config{
+		Settings: map[string]interface{}{},
+		Providers: map[string]map[string]interface{}{
+			"glm": {
+				"env": map[string]interface{}{
+					"HTTP_PROXY": "http://proxy:3128",
+				},
+			},
+			"glm-air": {
+				"extends": "glm",
+			},
+		},
+	}
+
+	// The key is only set on the parent, but glm-air inherits it
+	err := checkSettingsEnvConflict(cfg, "glm-air")
+	if err == nil || !strings.Contains(err.Error(), "HTTP_PROXY") {
+		t.Errorf("expected conflict for inherited key HTTP_PROXY, got: %v", err)
+	}
+}
+
 func TestCheckSettingsEnvConflict_UnknownProvider(t *testing.T) {
 	cleanup := setupTestDir(t)
 	defer cleanup()
@@ -226,3 +254,129 @@ func TestCheckValidateEnvConflict_NoConflict(t *testing.T) {
 		t.Errorf("expected no error when only safe env present, got: %v", err)
 	}
 }
+
+func TestRunClaude_SessionMode(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeSettingsJSON(t, `{"model":"user-model"}`)
+	before, _ := os.ReadFile(config.GetSettingsPath())
+
+	// Fake claude: record its arguments and a copy of the --settings file
+	dir := config.GetDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	copyFile := filepath.Join(dir, "session-copy.json")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" > " + argsFile + "\n" +
+		"cp \"$2\" " + copyFile + "\n" +
+		"exit 3\n"
+	fakeClaude := filepath.Join(dir, "fake-claude")
+	if err := os.WriteFile(fakeClaude, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CCC_CLAUDE", fakeClaude)
+
+	cfg := &config.Config{
+		Settings: map[string]interface{}{},
+		Providers: map[string]map[string]interface{}{
+			"glm": {
+				"model": "glm-4.7",
+				"env": map[string]interface{}{
+					"ANTHROPIC_BASE_URL":   "https://example.com",
+					"ANTHROPIC_AUTH_TOKEN": "sk-x",
+				},
+			},
+		},
+	}
+
+	err := runClaude(cfg, &Command{Provider: "glm", Session: true, ClaudeArgs: []string{"-p"}})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("runClaude() error = %v, want ExitError with code 3", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("fake claude was not run: %v", err)
+	}
+	if !strings.HasPrefix(string(args), "--settings ") || !strings.HasSuffix(strings.TrimSpace(string(args)), " -p") {
+		t.Errorf("claude args = %q, want --settings <file> ... -p", args)
+	}
+
+	copied, err := os.ReadFile(copyFile)
+	if err != nil {
+		t.Fatalf("session settings were not readable by claude: %v", err)
+	}
+	if !strings.Contains(string(copied), "glm-4.7") {
+		t.Errorf("session settings = %s, want provider model", copied)
+	}
+
+	sessionFile := strings.Fields(string(args))[1]
+	if _, err := os.Stat(sessionFile); !os.IsNotExist(err) {
+		t.Error("session settings file should be removed after claude exits")
+	}
+
+	after, _ := os.ReadFile(config.GetSettingsPath())
+	if string(before) != string(after) {
+		t.Error("session mode must not modify settings.json")
+	}
+}
+
+func TestRunClaude_ProjectFile(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	dir := config.GetDir()
+	argsFile := filepath.Join(dir, "args.txt")
+	copyFile := filepath.Join(dir, "session-copy.json")
+	script := "#!/bin/sh\n" +
+		"echo \"$@\" > " + argsFile + "\n" +
+		"cp \"$2\" " + copyFile + "\n"
+	fakeClaude := filepath.Join(dir, "fake-claude")
+	if err := os.WriteFile(fakeClaude, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CCC_CLAUDE", fakeClaude)
+
+	env := map[string]interface{}{
+		"ANTHROPIC_BASE_URL":   "https://example.com",
+		"ANTHROPIC_AUTH_TOKEN": "sk-x",
+	}
+	cfg := &config.Config{
+		Settings:        map[string]interface{}{},
+		ClaudeArgs:      []string{"--global"},
+		CurrentProvider: "glm",
+		Providers: map[string]map[string]interface{}{
+			"glm":      {"env": env},
+			"internal": {"env": env, "model": "internal-model"},
+		},
+		Project: &config.ProjectConfig{
+			Provider:   "internal",
+			Settings:   map[string]interface{}{"permissions": map[string]interface{}{"defaultMode": "plan"}},
+			ClaudeArgs: []string{"--project"},
+			Path:       "/repo/.ccc.json",
+		},
+	}
+
+	if err := runClaude(cfg, &Command{Session: true, ClaudeArgs: []string{"-p"}}); err != nil {
+		t.Fatalf("runClaude() error = %v", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("fake claude was not run: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(args)), "--global --project -p") {
+		t.Errorf("claude args = %q, want global, project then command line args", args)
+	}
+
+	copied, err := os.ReadFile(copyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"internal-model", `"defaultMode": "plan"`} {
+		if !strings.Contains(string(copied), want) {
+			t.Errorf("session settings = %s, want %s", copied, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/provider"
+)
+
+// ExplainCommandOptions represents options for the explain command.
+type ExplainCommandOptions struct {
+	Args []string // [provider] [key.path]
+	JSON bool     // --json flag, print JSON for scripts
+}
+
+// explainUsage is shown when the explain command is used incorrectly.
+const explainUsage = `usage: ccc explain [provider] [key.path] [--json]`
+
+// parseExplainArgs parses arguments for the explain command.
+// Flags may appear before or after the positional arguments.
+func parseExplainArgs(args []string) *ExplainCommandOptions {
+	opts := &ExplainCommandOptions{}
+
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	fs.Usage = func() {} // Suppress default usage output
+	fs.BoolVar(&opts.JSON, "json", false, "print JSON")
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		// The unknown flag is left in Args, so runExplain prints the usage
+		opts.Args = args
+		return opts
+	}
+	opts.Args = positional
+	return opts
+}
+
+// runExplain prints the settings and env a provider is launched with, and
+// the layer each value comes from. Secrets are masked.
+func runExplain(cfg *config.Config, opts *ExplainCommandOptions) error {
+	if len(opts.Args) > 2 {
+		return fmt.Errorf("%s", explainUsage)
+	}
+	for _, arg := range opts.Args {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("%s", explainUsage)
+		}
+	}
+
+	// A single argument is a provider if one has that name, else a key path
+	var providerName, keyPath string
+	switch len(opts.Args) {
+	case 2:
+		providerName, keyPath = opts.Args[0], opts.Args[1]
+	case 1:
+		if _, exists := cfg.Providers[opts.Args[0]]; exists {
+			providerName = opts.Args[0]
+		} else {
+			keyPath = opts.Args[0]
+		}
+	}
+	if providerName == "" {
+		steps, selected, _ := lookupProvider(&Command{}, cfg)
+		if selected < 0 {
+			return fmt.Errorf("no providers configured")
+		}
+		providerName = steps[selected].name
+	}
+	if _, exists := cfg.Providers[providerName]; !exists {
+		return fmt.Errorf("provider '%s' not found", providerName)
+	}
+	if keyPath != "" {
+		if _, err := config.SplitPath(keyPath); err != nil {
+			return err
+		}
+	}
+
+	values, err := provider.Explain(cfg, providerName)
+	if err != nil {
+		return err
+	}
+	var shown []provider.ExplainedValue
+	for _, v := range values {
+		if keyPath != "" && v.Path != keyPath && !strings.HasPrefix(v.Path, keyPath+".") {
+			continue
+		}
+		keys := strings.Split(v.Path, ".")
+		v.Value = config.MaskSecrets(keys[len(keys)-1], v.Value)
+		shown = append(shown, v)
+	}
+	if keyPath != "" && len(shown) == 0 {
+		return fmt.Errorf("'%s' is not set for provider '%s'", keyPath, providerName)
+	}
+
+	if opts.JSON {
+		data, err := encodeJSON(map[string]interface{}{
+			"provider": providerName,
+			"values":   shown,
+		}, "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format values: %w", err)
+		}
+		fmt.Println(data)
+		return nil
+	}
+
+	fmt.Printf("Provider: %s\n", providerName)
+	if len(shown) == 0 {
+		fmt.Println("\nNo settings.")
+		return nil
+	}
+	rows := make([][3]string, len(shown))
+	pathWidth, valueWidth := 0, 0
+	for i, v := range shown {
+		value, err := encodeJSON(v.Value, "")
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", v.Path, err)
+		}
+		sources := make([]string, len(v.Sources))
+		for j, source := range v.Sources {
+			sources[j] = source.String()
+		}
+		rows[i] = [3]string{v.Path, value, strings.Join(sources, " + ")}
+		pathWidth = max(pathWidth, len(v.Path))
+		// Long values don't push every source off the screen
+		valueWidth = max(valueWidth, min(len(value), 40))
+	}
+	fmt.Println()
+	for _, row := range rows {
+		fmt.Printf("  %-*s  %-*s  %s\n", pathWidth, row[0], valueWidth, row[1], row[2])
+	}
+	return nil
+}
+
+// encodeJSON formats v as JSON, indented by indent per level (compact if
+// empty). Unlike json.Marshal it leaves &, < and > as they are, so commands
+// and hooks read as written.
+func encodeJSON(v interface{}, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
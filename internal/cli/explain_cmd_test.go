@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestRunExplain(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cmd := Parse([]string{"explain", "glm", "--json"})
+	if !cmd.Explain || !cmd.ExplainOpts.JSON || len(cmd.ExplainOpts.Args) != 1 {
+		t.Fatalf("Parse(explain glm --json) = %+v", cmd)
+	}
+
+	cfg := &config.Config{
+		Settings:        map[string]interface{}{"alwaysThinkingEnabled": true},
+		CurrentProvider: "kimi",
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{
+				"ANTHROPIC_AUTH_TOKEN": "sk-glm-1234567890abcdef",
+				"ANTHROPIC_MODEL":      "glm-4.7",
+			}, "statusLine": map[string]interface{}{"command": "git branch && date"}},
+			"kimi": {"model": "kimi-k2"},
+		},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The current provider by default
+	output := captureStdout(t, func() {
+		if err := runExplain(cfg, parseExplainArgs(nil)); err != nil {
+			t.Errorf("runExplain() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Provider: kimi") || !strings.Contains(output, `model                  "kimi-k2"  provider kimi (ccc.json)`) {
+		t.Errorf("output = %q", output)
+	}
+
+	output = captureStdout(t, func() {
+		if err := runExplain(cfg, parseExplainArgs([]string{"glm", "env", "--json"})); err != nil {
+			t.Errorf("runExplain(glm env --json) error = %v", err)
+		}
+	})
+	var result struct {
+		Provider string `json:"provider"`
+		Values   []struct {
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		} `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, output)
+	}
+	if result.Provider != "glm" || len(result.Values) != 2 || result.Values[0].Value != "sk-g****cdef" {
+		t.Errorf("result = %+v, want the two env values of glm with the token masked", result)
+	}
+
+	// A single argument that isn't a provider is a key path
+	output = captureStdout(t, func() {
+		if err := runExplain(cfg, parseExplainArgs([]string{"alwaysThinkingEnabled"})); err != nil {
+			t.Errorf("runExplain(alwaysThinkingEnabled) error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "alwaysThinkingEnabled  true  settings (ccc.json)") {
+		t.Errorf("output = %q", output)
+	}
+
+	// Commands are shown as written, in the table and in JSON
+	for _, args := range [][]string{{"glm", "statusLine"}, {"glm", "statusLine", "--json"}} {
+		output = captureStdout(t, func() {
+			if err := runExplain(cfg, parseExplainArgs(args)); err != nil {
+				t.Errorf("runExplain(%q) error = %v", args, err)
+			}
+		})
+		if !strings.Contains(output, `"git branch && date"`) {
+			t.Errorf("runExplain(%q) output = %q, want && unescaped", args, output)
+		}
+	}
+
+	for _, args := range [][]string{{"glm", "theme"}, {"nope", "model"}, {"a", "b", "c"}, {"--yaml"}} {
+		if err := runExplain(cfg, parseExplainArgs(args)); err == nil {
+			t.Errorf("runExplain(%q) should fail", args)
+		}
+	}
+}
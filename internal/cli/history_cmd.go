@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/jsonc"
+)
+
+// HistoryCommandOptions represents options for the history, undo and restore commands.
+type HistoryCommandOptions struct {
+	Action string   // history, undo or restore
+	Args   []string // positional arguments after the command
+}
+
+// historyUsage is shown when a history command is used incorrectly.
+const historyUsage = `usage: ccc history config
+       ccc undo
+       ccc restore <id>`
+
+// historyDiffFiles are the snapshot files shown by `ccc history config`.
+var historyDiffFiles = []string{"settings.json", "ccc.json", config.SecretsFileName}
+
+// runHistory executes the history, undo and restore commands.
+func runHistory(opts *HistoryCommandOptions) error {
+	switch {
+	case opts.Action == "history" && (len(opts.Args) == 0 || (len(opts.Args) == 1 && opts.Args[0] == "config")):
+		return showHistory()
+	case opts.Action == "undo" && len(opts.Args) == 0:
+		return undo()
+	case opts.Action == "restore" && len(opts.Args) == 1:
+		return restore(opts.Args[0])
+	}
+	return fmt.Errorf("%s", historyUsage)
+}
+
+// showHistory lists the snapshots, newest first, each with the changes made
+// after it was taken.
+func showHistory() error {
+	snapshots, err := config.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots yet. ccc takes one each time it changes settings.json or ccc.json.")
+		return nil
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		fmt.Printf("%s  %s  %s\n", s.ID, s.Time.Local().Format("2006-01-02 15:04:05"), s.Reason)
+
+		for _, name := range historyDiffFiles {
+			before, beforeExists, err := config.ReadSnapshotFile(s, name)
+			if err != nil {
+				return err
+			}
+			// The change is what happened between this snapshot and the next state
+			var after []byte
+			var afterExists bool
+			if i+1 < len(snapshots) {
+				after, afterExists, err = config.ReadSnapshotFile(snapshots[i+1], name)
+			} else {
+				after, afterExists, err = config.ReadCurrentFile(name)
+			}
+			if err != nil {
+				return err
+			}
+			printFileDiff(name, before, beforeExists, after, afterExists)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("Undo the latest change with `ccc undo`, or go back to a snapshot with `ccc restore <id>`.")
+	return nil
+}
+
+// printFileDiff prints the changed lines of a file, if any, with secrets
+// masked. The lines of a file that isn't valid JSON are not shown, as its
+// secrets can't be told apart.
+func printFileDiff(name string, before []byte, beforeExists bool, after []byte, afterExists bool) {
+	if beforeExists == afterExists && bytes.Equal(before, after) {
+		return
+	}
+	maskedBefore, beforeOK := maskDocument(before)
+	maskedAfter, afterOK := maskDocument(after)
+	var lines []string
+	if beforeOK && afterOK {
+		lines = diffLines(splitLines(maskedBefore), splitLines(maskedAfter))
+	} else {
+		lines = []string{"(not valid JSON; changes not shown)"}
+	}
+	if beforeExists == afterExists && len(lines) == 0 {
+		return
+	}
+
+	switch {
+	case !beforeExists:
+		fmt.Printf("  %s (created)\n", name)
+	case !afterExists:
+		fmt.Printf("  %s (removed)\n", name)
+	default:
+		fmt.Printf("  %s\n", name)
+	}
+	for _, line := range lines {
+		fmt.Printf("    %s\n", line)
+	}
+}
+
+// undo restores the latest snapshot and removes it from the history,
+// so repeated undos walk further back. The files it replaces are snapshotted
+// first, so edits made since can be restored.
+func undo() error {
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	snapshots, err := config.ListSnapshots()
+	if err != nil {
+		return err
+	}
+	// Snapshots taken by earlier undos are skipped
+	var latest *config.Snapshot
+	for i := len(snapshots) - 1; i >= 0 && latest == nil; i-- {
+		if !snapshots[i].Undo {
+			latest = snapshots[i]
+		}
+	}
+	if latest == nil {
+		return fmt.Errorf("nothing to undo: no snapshots in %s", config.GetHistoryDir())
+	}
+
+	saved, err := config.Undo(latest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Undid: %s (restored snapshot %s)\n", latest.Reason, latest.ID)
+	if saved != nil {
+		fmt.Printf("The replaced files are kept in snapshot %s; run `ccc restore %s` to bring them back\n", saved.ID, saved.ID)
+	}
+	return nil
+}
+
+// restore puts back the files of a snapshot. The current files are
+// snapshotted, so a restore can itself be undone.
+func restore(id string) error {
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	snapshot, err := config.FindSnapshot(id)
+	if err != nil {
+		return err
+	}
+	if err := config.WithSnapshot(fmt.Sprintf("restore %s", id), func() error {
+		return config.RestoreSnapshot(snapshot)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Restored snapshot %s (%s)\n", snapshot.ID, snapshot.Reason)
+	return nil
+}
+
+// splitLines splits file content into lines, ignoring a trailing newline.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// diffLines returns the lines removed from a ("- ") and added in b ("+ "),
+// in order, based on their longest common subsequence.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	return out
+}
+
+// maskDocument returns a JSON(C) file with the values of secret-looking keys
+// masked (see config.MaskSecrets), keeping its layout so diffs stay readable.
+// It returns false if the file can't be parsed and so can't be masked.
+func maskDocument(data []byte) ([]byte, bool) {
+	if len(data) == 0 {
+		return data, true
+	}
+	var tree interface{}
+	if err := json.Unmarshal(jsonc.Strip(data), &tree); err != nil {
+		return nil, false
+	}
+	masked, err := json.Marshal(config.MaskSecrets("", tree))
+	if err != nil {
+		return nil, false
+	}
+	patched, err := jsonc.Patch(data, masked)
+	if err != nil {
+		return nil, false
+	}
+	return patched, true
+}
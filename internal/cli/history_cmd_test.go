@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/provider"
+)
+
+func TestParseHistory(t *testing.T) {
+	cmd := Parse([]string{"restore", "20261016-101500"})
+	want := &HistoryCommandOptions{Action: "restore", Args: []string{"20261016-101500"}}
+	if !cmd.HistoryCmd || !reflect.DeepEqual(cmd.HistoryOpts, want) {
+		t.Errorf("Parse(restore) = %+v, want history command %+v", cmd, want)
+	}
+
+	for _, name := range []string{"history", "undo", "restore"} {
+		if !IsReservedName(name) {
+			t.Errorf("IsReservedName(%q) = false, want true", name)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"{", `  "model": "kimi",`, `  "theme": "dark"`, "}"}
+	b := []string{"{", `  "model": "glm",`, `  "theme": "dark",`, `  "verbose": true`, "}"}
+
+	got := diffLines(a, b)
+	want := []string{
+		`-   "model": "kimi",`,
+		`-   "theme": "dark"`,
+		`+   "model": "glm",`,
+		`+   "theme": "dark",`,
+		`+   "verbose": true`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if got := diffLines(a, a); len(got) != 0 {
+		t.Errorf("diffLines() of equal input = %v, want none", got)
+	}
+}
+
+func TestMaskDocument(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{
+			"{\n  \"env\": {\n    \"ANTHROPIC_AUTH_TOKEN\": \"sk-1234567890abcdef\",\n    \"ANTHROPIC_MODEL\": \"glm-4.7\"\n  }\n}",
+			"{\n  \"env\": {\n    \"ANTHROPIC_AUTH_TOKEN\": \"sk-1****cdef\",\n    \"ANTHROPIC_MODEL\": \"glm-4.7\"\n  }\n}",
+		},
+		// Several pairs on one line, with a comment
+		{
+			"{\n  // glm\n  \"env\": {\"ANTHROPIC_AUTH_TOKEN\": \"sk-1234567890abcdef\", \"API_KEY\": \"short\"}\n}",
+			"{\n  // glm\n  \"env\": {\"ANTHROPIC_AUTH_TOKEN\": \"sk-1****cdef\", \"API_KEY\": \"****\"}\n}",
+		},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := maskDocument([]byte(tt.data))
+		if !ok || string(got) != tt.want {
+			t.Errorf("maskDocument(%q) = %q, %v, want %q", tt.data, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := maskDocument([]byte(`{"API_KEY": "sk-1234567890abcdef"`)); ok {
+		t.Error("maskDocument() of invalid JSON should fail")
+	}
+}
+
+func TestUndoAndRestore(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	run := func(args ...string) error {
+		return runHistory(Parse(args).HistoryOpts)
+	}
+	model := func() interface{} {
+		settings, err := config.LoadSettings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return settings["model"]
+	}
+	change := func(value string) {
+		if _, err := config.TakeSnapshot("set model " + value); err != nil {
+			t.Fatal(err)
+		}
+		if err := config.SaveSettings(map[string]interface{}{"model": value}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	change("a")
+	change("b")
+	change("c")
+	snapshots, _ := config.ListSnapshots()
+	if len(snapshots) != 3 {
+		t.Fatalf("len(snapshots) = %d, want 3", len(snapshots))
+	}
+
+	if err := run("undo"); err != nil {
+		t.Fatalf("undo error = %v", err)
+	}
+	if model() != "b" {
+		t.Errorf("model after undo = %v, want b", model())
+	}
+	if err := run("undo"); err != nil {
+		t.Fatalf("second undo error = %v", err)
+	}
+	if model() != "a" {
+		t.Errorf("model after second undo = %v, want a", model())
+	}
+
+	// Restoring the first snapshot removes settings.json, and can be undone
+	if err := run("restore", snapshots[0].ID); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+	if settings, _ := config.LoadSettings(); settings != nil {
+		t.Errorf("settings.json = %v, want removed", settings)
+	}
+	if err := run("undo"); err != nil {
+		t.Fatalf("undo of restore error = %v", err)
+	}
+	if model() != "a" {
+		t.Errorf("model after undoing restore = %v, want a", model())
+	}
+
+	if err := run("restore", "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("restore of unknown snapshot error = %v", err)
+	}
+	if err := run("history", "sessions"); err == nil || !strings.Contains(err.Error(), "usage") {
+		t.Errorf("unknown history target error = %v, want usage", err)
+	}
+}
+
+func TestUndoKeepsLaterEdits(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := &config.Config{
+		Providers: map[string]map[string]interface{}{
+			"glm": {"model": "glm-4.7"},
+		},
+	}
+	if err := config.Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.SwitchWithHook(cfg, "glm"); err != nil {
+		t.Fatalf("SwitchWithHook() error = %v", err)
+	}
+
+	// The user (or claude) edits settings.json after the switch
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings["theme"] = "dark"
+	if err := config.SaveSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) error {
+		return runHistory(Parse(args).HistoryOpts)
+	}
+	if err := run("undo"); err != nil {
+		t.Fatalf("undo error = %v", err)
+	}
+	if settings, _ := config.LoadSettings(); settings != nil {
+		t.Errorf("settings.json after undo = %v, want removed", settings)
+	}
+
+	// The snapshot of the undo is skipped by further undos...
+	if err := run("undo"); err == nil || !strings.Contains(err.Error(), "nothing to undo") {
+		t.Errorf("second undo error = %v, want nothing to undo", err)
+	}
+
+	// ...but brings the edit back
+	snapshots, err := config.ListSnapshots()
+	if err != nil || len(snapshots) != 1 || !snapshots[0].Undo {
+		t.Fatalf("ListSnapshots() = %v, %v, want the undo snapshot", snapshots, err)
+	}
+	if err := run("restore", snapshots[0].ID); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+	settings, err = config.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["theme"] != "dark" || settings["model"] != "glm-4.7" {
+		t.Errorf("settings.json after restore = %v, want the edit back", settings)
+	}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/migration"
+)
+
+// MigrateCommandOptions represents options for the migrate command.
+type MigrateCommandOptions struct {
+	DryRun bool     // --dry-run flag, only show what would change
+	Args   []string // unexpected positional arguments
+}
+
+// migrateUsage is shown when the migrate command is used incorrectly.
+const migrateUsage = `usage: ccc migrate [--dry-run]`
+
+// parseMigrateArgs parses arguments for the migrate command.
+func parseMigrateArgs(args []string) *MigrateCommandOptions {
+	opts := &MigrateCommandOptions{}
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.Usage = func() {} // Suppress default usage output
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "only show what would change")
+	if err := fs.Parse(args); err != nil {
+		opts.Args = args
+		return opts
+	}
+	opts.Args = fs.Args()
+	return opts
+}
+
+// runMigrate executes the migrate command.
+func runMigrate(opts *MigrateCommandOptions) error {
+	if len(opts.Args) > 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	if !opts.DryRun {
+		unlock, err := config.Lock()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	pending := migration.Pending(cfg)
+	if len(pending) == 0 {
+		fmt.Printf("ccc.json is up to date (version %d)\n", cfg.Version)
+		return nil
+	}
+
+	if opts.DryRun {
+		s, err := migration.Plan(cfg)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pending migrations of ccc.json (version %d to %d):\n", cfg.Version, s.Config.Version)
+		for _, m := range pending {
+			fmt.Printf("  %d: %s\n", m.Version, m.Description)
+		}
+		printMigrationChanges(s.Changes)
+		fmt.Println("\nRun `ccc migrate` to apply them.")
+		return nil
+	}
+
+	from := cfg.Version
+	s, backupDir, err := migration.Migrate(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated ccc.json from version %d to %d\n", from, s.Config.Version)
+	printMigrationChanges(s.Changes)
+	if backupDir != "" {
+		fmt.Printf("\nOriginal files backed up to %s\n", backupDir)
+	}
+	return nil
+}
+
+// printMigrationChanges lists the changes made by migrations.
+func printMigrationChanges(changes []string) {
+	if len(changes) == 0 {
+		fmt.Println("No files need to change; only the version is updated.")
+		return
+	}
+	fmt.Println("Changes:")
+	for _, change := range changes {
+		fmt.Printf("  - %s\n", change)
+	}
+}
+
+// migrateOnLoad runs the pending migrations before ccc uses the
+// configuration, and returns it reloaded. Migrations run once, so later
+// launches skip this entirely.
+func migrateOnLoad() (*config.Config, error) {
+	unlock, err := config.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// Another ccc process may have migrated meanwhile
+	cfg, err := config.Load()
+	if err != nil || len(migration.Pending(cfg)) == 0 {
+		return cfg, err
+	}
+	s, backupDir, err := migration.Migrate(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate ccc.json: %w", err)
+	}
+	if len(s.Changes) > 0 {
+		fmt.Fprintf(os.Stderr, "Migrated ccc.json to version %d (%d change(s), backup in %s; see `ccc history config`)\n",
+			s.Config.Version, len(s.Changes), backupDir)
+	}
+	return config.Load()
+}
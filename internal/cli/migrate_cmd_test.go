@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestParseMigrate(t *testing.T) {
+	cmd := Parse([]string{"migrate", "--dry-run"})
+	if !cmd.Migrate || !cmd.MigrateOpts.DryRun {
+		t.Errorf("Parse(migrate --dry-run) = %+v", cmd)
+	}
+
+	// claude's own migrate-installer is passed through
+	cmd = Parse([]string{"migrate-installer"})
+	if cmd.Migrate || len(cmd.ClaudeArgs) != 1 {
+		t.Errorf("Parse(migrate-installer) = %+v, want it passed to claude", cmd)
+	}
+
+	if err := runMigrate(parseMigrateArgs([]string{"now"})); err == nil {
+		t.Error("runMigrate(now) should fail with the usage")
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	settings := `{"hooks": {"Stop": [{"hooks": [{"type": "command", "command": "ccc supervisor-hook"}]}]}}`
+	if err := os.WriteFile(config.GetSettingsPath(), []byte(settings), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.GetConfigPath(), []byte(`{"providers": {"glm": {}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := runMigrate(parseMigrateArgs([]string{"--dry-run"})); err != nil {
+			t.Errorf("dry run error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "version 0 to 1") || !strings.Contains(output, "settings.json: remove the supervisor Stop hook") {
+		t.Errorf("dry run output = %q", output)
+	}
+	if data, _ := os.ReadFile(config.GetSettingsPath()); string(data) != settings {
+		t.Errorf("dry run changed settings.json:\n%s", data)
+	}
+
+	output = captureStdout(t, func() {
+		if err := runMigrate(parseMigrateArgs(nil)); err != nil {
+			t.Errorf("migrate error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Migrated ccc.json from version 0 to 1") || !strings.Contains(output, "backed up to") {
+		t.Errorf("migrate output = %q", output)
+	}
+	if data, _ := os.ReadFile(config.GetSettingsPath()); strings.Contains(string(data), "supervisor") {
+		t.Errorf("settings.json still has the hook:\n%s", data)
+	}
+
+	output = captureStdout(t, func() {
+		if err := runMigrate(parseMigrateArgs(nil)); err != nil {
+			t.Errorf("second migrate error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "up to date (version 1)") {
+		t.Errorf("second migrate output = %q", output)
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+	"github.com/guyskk/ccc/internal/provider"
+)
+
+// ProviderCommandOptions represents options for the provider command.
+type ProviderCommandOptions struct {
+	Action  string   // add, remove, rename or copy
+	Args    []string // positional arguments after the action
+	BaseURL string   // --base-url for add
+	Token   string   // --token for add
+	Model   string   // --model for add
+}
+
+// providerUsage is shown when the provider command is used incorrectly.
+const providerUsage = `usage: ccc provider add <name> [--base-url URL] [--token TOKEN] [--model MODEL]
+       ccc provider remove <name>
+       ccc provider rename <old> <new>
+       ccc provider copy <src> <dst>`
+
+// parseProviderArgs parses arguments for the provider command.
+// Flags may appear before or after the positional arguments.
+func parseProviderArgs(args []string) *ProviderCommandOptions {
+	opts := &ProviderCommandOptions{}
+	if len(args) == 0 {
+		return opts
+	}
+	opts.Action = args[0]
+
+	fs := flag.NewFlagSet("provider", flag.ContinueOnError)
+	fs.Usage = func() {} // Suppress default usage output
+	fs.StringVar(&opts.BaseURL, "base-url", "", "ANTHROPIC_BASE_URL of the new provider")
+	fs.StringVar(&opts.Token, "token", "", "ANTHROPIC_AUTH_TOKEN of the new provider")
+	fs.StringVar(&opts.Model, "model", "", "ANTHROPIC_MODEL of the new provider")
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if err != nil {
+		// On parse error, return options without an action
+		return &ProviderCommandOptions{}
+	}
+	opts.Args = positional
+
+	return opts
+}
+
+// runProvider executes the provider command.
+func runProvider(opts *ProviderCommandOptions) error {
+	wantArgs := map[string]int{"add": 1, "remove": 1, "rename": 2, "copy": 2}
+	if n, ok := wantArgs[opts.Action]; !ok || len(opts.Args) != n {
+		return fmt.Errorf("%s", providerUsage)
+	}
+
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		// Adding the first provider creates ccc.json
+		if opts.Action != "add" || !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		cfg = &config.Config{
+			Settings:  make(map[string]interface{}),
+			Providers: make(map[string]map[string]interface{}),
+		}
+	}
+
+	// Names given to new providers must not collide with subcommands
+	newName := opts.Args[len(opts.Args)-1]
+	if opts.Action != "remove" && IsReservedName(newName) {
+		return fmt.Errorf("provider name '%s' is reserved for a ccc or claude subcommand", newName)
+	}
+
+	var message string
+	switch opts.Action {
+	case "add":
+		err = provider.Add(cfg, newName, newProviderSettings(opts))
+		message = fmt.Sprintf("Added provider: %s", newName)
+	case "remove":
+		err = provider.Remove(cfg, opts.Args[0])
+		message = fmt.Sprintf("Removed provider: %s", opts.Args[0])
+	case "rename":
+		err = provider.Rename(cfg, opts.Args[0], newName)
+		message = fmt.Sprintf("Renamed provider: %s -> %s", opts.Args[0], newName)
+	case "copy":
+		err = provider.Copy(cfg, opts.Args[0], newName)
+		message = fmt.Sprintf("Copied provider: %s -> %s", opts.Args[0], newName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := config.WithSnapshot(fmt.Sprintf("provider %s %s", opts.Action, strings.Join(opts.Args, " ")), func() error {
+		return config.Save(cfg)
+	}); err != nil {
+		return err
+	}
+	fmt.Println(message)
+	return nil
+}
+
+// newProviderSettings builds the settings of a provider created by `ccc provider add`.
+func newProviderSettings(opts *ProviderCommandOptions) map[string]interface{} {
+	env := make(map[string]interface{})
+	if opts.BaseURL != "" {
+		env["ANTHROPIC_BASE_URL"] = opts.BaseURL
+	}
+	if opts.Token != "" {
+		env["ANTHROPIC_AUTH_TOKEN"] = opts.Token
+	}
+	if opts.Model != "" {
+		env["ANTHROPIC_MODEL"] = opts.Model
+	}
+
+	settings := make(map[string]interface{})
+	if len(env) > 0 {
+		settings["env"] = env
+	}
+	return settings
+}
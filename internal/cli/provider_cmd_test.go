@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestParseProviderArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want *ProviderCommandOptions
+	}{
+		{
+			name: "no args",
+			args: []string{},
+			want: &ProviderCommandOptions{},
+		},
+		{
+			name: "add with flags after name",
+			args: []string{"add", "glm", "--base-url", "https://x", "--token", "sk-x", "--model", "glm-4.7"},
+			want: &ProviderCommandOptions{
+				Action: "add", Args: []string{"glm"},
+				BaseURL: "https://x", Token: "sk-x", Model: "glm-4.7",
+			},
+		},
+		{
+			name: "add with flags before name",
+			args: []string{"add", "--model=glm-4.7", "glm"},
+			want: &ProviderCommandOptions{Action: "add", Args: []string{"glm"}, Model: "glm-4.7"},
+		},
+		{
+			name: "rename",
+			args: []string{"rename", "kimi", "moonshot"},
+			want: &ProviderCommandOptions{Action: "rename", Args: []string{"kimi", "moonshot"}},
+		},
+		{
+			name: "unknown flag returns no action",
+			args: []string{"add", "glm", "--unknown"},
+			want: &ProviderCommandOptions{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseProviderArgs(tt.args)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProviderArgs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunProvider(t *testing.T) {
+	t.Run("add creates ccc.json when missing", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		opts := parseProviderArgs([]string{"add", "glm", "--base-url", "https://glm", "--token", "sk-glm"})
+		if err := runProvider(opts); err != nil {
+			t.Fatalf("runProvider() error = %v", err)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if config.GetBaseURL(cfg.Providers["glm"]) != "https://glm" {
+			t.Errorf("base url = %q, want https://glm", config.GetBaseURL(cfg.Providers["glm"]))
+		}
+		if config.GetAuthToken(cfg.Providers["glm"]) != "sk-glm" {
+			t.Error("token should be saved")
+		}
+	})
+
+	t.Run("rename updates current provider", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		if err := config.Save(&config.Config{
+			CurrentProvider: "kimi",
+			Providers:       map[string]map[string]interface{}{"kimi": {}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := runProvider(parseProviderArgs([]string{"rename", "kimi", "moonshot"})); err != nil {
+			t.Fatalf("runProvider() error = %v", err)
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if cfg.CurrentProvider != "moonshot" {
+			t.Errorf("CurrentProvider = %q, want moonshot", cfg.CurrentProvider)
+		}
+	})
+
+	t.Run("reserved name is rejected", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		err := runProvider(parseProviderArgs([]string{"add", "mcp"}))
+		if err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Errorf("runProvider() error = %v, want reserved name error", err)
+		}
+	})
+
+	t.Run("wrong argument count shows usage", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		err := runProvider(parseProviderArgs([]string{"rename", "kimi"}))
+		if err == nil || !strings.Contains(err.Error(), "usage: ccc provider") {
+			t.Errorf("runProvider() error = %v, want usage", err)
+		}
+	})
+}
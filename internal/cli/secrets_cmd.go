@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// SecretsCommandOptions represents options for the secrets command.
+type SecretsCommandOptions struct {
+	Action string   // split, encrypt or unlock
+	Args   []string // positional arguments after the action
+}
+
+// secretsUsage is shown when the secrets command is used incorrectly.
+const secretsUsage = `usage: ccc secrets split
+       ccc secrets encrypt
+       eval "$(ccc secrets unlock)"`
+
+// parseSecretsArgs parses arguments for the secrets command.
+func parseSecretsArgs(args []string) *SecretsCommandOptions {
+	opts := &SecretsCommandOptions{}
+	if len(args) > 0 {
+		opts.Action = args[0]
+		opts.Args = args[1:]
+	}
+	return opts
+}
+
+// runSecrets executes the secrets command.
+func runSecrets(opts *SecretsCommandOptions) error {
+	if len(opts.Args) != 0 {
+		return fmt.Errorf("%s", secretsUsage)
+	}
+	switch opts.Action {
+	case "split":
+		return splitSecrets()
+	case "encrypt":
+		return encryptSecrets()
+	case "unlock":
+		return unlockSecrets()
+	}
+	return fmt.Errorf("%s", secretsUsage)
+}
+
+// splitSecrets moves the tokens of ccc.json to the secrets file.
+func splitSecrets() error {
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	moved, err := config.SplitSecrets(cfg)
+	if err != nil {
+		return err
+	}
+	if len(moved) == 0 {
+		fmt.Println("No secrets found in ccc.json")
+		return nil
+	}
+
+	if err := config.WithSnapshot("secrets split", func() error {
+		return config.Save(cfg)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Moved %d secret(s) to %s:\n", len(moved), config.GetSecretsPath())
+	for _, path := range moved {
+		fmt.Printf("  %s\n", path)
+	}
+	return nil
+}
+
+// encryptSecrets replaces the plain text secrets of ccc.json and the secrets
+// file with values encrypted with a passphrase.
+func encryptSecrets() error {
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	passphrase, err := encryptionPassphrase(cfg)
+	if err != nil {
+		return err
+	}
+	plain := providerEnvValues(cfg)
+	encrypted, err := config.EncryptSecrets(cfg, passphrase)
+	if err != nil {
+		return err
+	}
+	if len(encrypted) == 0 {
+		fmt.Println("No plain text secrets found")
+		return nil
+	}
+
+	// No snapshot is taken: it would keep the plain text secrets around
+	if err := config.Save(cfg); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %d secret(s):\n", len(encrypted))
+	for _, path := range encrypted {
+		fmt.Printf("  %s\n", path)
+	}
+
+	// Encrypt the same values in the snapshots, so `ccc undo` can't bring
+	// them back in plain text
+	replace := make(map[string]string, len(encrypted))
+	encryptedValues := providerEnvValues(cfg)
+	for _, path := range encrypted {
+		replace[plain[path]] = encryptedValues[path]
+	}
+	rewritten, err := config.ReplaceInSnapshots(replace)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secrets kept in the history: %w", err)
+	}
+	if len(rewritten) > 0 {
+		fmt.Printf("Encrypted them in %d snapshot(s) of the history as well.\n", len(rewritten))
+	}
+	plainValues := make([]string, 0, len(replace))
+	for value := range replace {
+		plainValues = append(plainValues, value)
+	}
+	leaked, err := config.SnapshotsContaining(plainValues)
+	if err != nil {
+		return err
+	}
+	if len(leaked) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: snapshot(s) %s still hold secrets in plain text; remove them from %s\n",
+			strings.Join(leaked, ", "), config.GetHistoryDir())
+	}
+	fmt.Printf("ccc asks for the passphrase when it needs them; set %s to skip the prompt.\n", config.PassphraseEnv)
+	return nil
+}
+
+// providerEnvValues returns the string env values of the providers of cfg by
+// path, e.g. "providers.glm.env.ANTHROPIC_AUTH_TOKEN".
+func providerEnvValues(cfg *config.Config) map[string]string {
+	values := make(map[string]string)
+	for name, settings := range cfg.Providers {
+		for key, v := range config.GetEnv(settings) {
+			if s, ok := v.(string); ok {
+				values[strings.Join([]string{"providers", name, "env", key}, ".")] = s
+			}
+		}
+	}
+	return values
+}
+
+// encryptionPassphrase returns the passphrase to encrypt with. A new
+// passphrase entered on the terminal is asked for twice; one that values are
+// already encrypted with must match them.
+func encryptionPassphrase(cfg *config.Config) (string, error) {
+	passphrase, fromEnv := os.LookupEnv(config.PassphraseEnv)
+	if !fromEnv {
+		var err error
+		if passphrase, err = config.PromptPassphrase("Passphrase: "); err != nil {
+			return "", err
+		}
+	}
+	if err := config.CheckPassphrase(cfg, passphrase); err != nil {
+		return "", fmt.Errorf("the passphrase doesn't match the encrypted values: %w", err)
+	}
+	if !fromEnv && !hasEncryptedValues(cfg) {
+		confirm, err := config.PromptPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if confirm != passphrase {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+	return passphrase, nil
+}
+
+// hasEncryptedValues reports whether a provider env of cfg holds an encrypted value.
+func hasEncryptedValues(cfg *config.Config) bool {
+	for _, settings := range cfg.Providers {
+		for _, v := range config.GetEnv(settings) {
+			if s, ok := v.(string); ok && config.IsEncrypted(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unlockSecrets asks for the passphrase once and prints a shell command
+// exporting it, so later ccc runs in that shell don't ask again.
+func unlockSecrets() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	passphrase, err := config.PromptPassphrase("Passphrase: ")
+	if err != nil {
+		return err
+	}
+	if err := config.CheckPassphrase(cfg, passphrase); err != nil {
+		return fmt.Errorf("wrong passphrase: %w", err)
+	}
+	fmt.Printf("export %s=%s\n", config.PassphraseEnv, shellQuote(passphrase))
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestRunSecrets(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cmd := Parse([]string{"secrets", "split"})
+	if !cmd.SecretsCmd || cmd.SecretsOpts.Action != "split" {
+		t.Fatalf("Parse(secrets split) = %+v", cmd)
+	}
+	if err := runSecrets(parseSecretsArgs([]string{"show"})); err == nil {
+		t.Error("runSecrets(show) should fail with the usage")
+	}
+
+	if err := config.Save(&config.Config{
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{
+				"ANTHROPIC_BASE_URL":   "https://glm",
+				"ANTHROPIC_AUTH_TOKEN": "sk-glm",
+			}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := Run(cmd); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Moved 1 secret(s)") || !strings.Contains(output, "providers.glm.env.ANTHROPIC_AUTH_TOKEN") {
+		t.Errorf("output = %q, want the moved token", output)
+	}
+	if data, _ := os.ReadFile(config.GetConfigPath()); strings.Contains(string(data), "sk-glm") {
+		t.Errorf("ccc.json still holds the token:\n%s", data)
+	}
+
+	output = captureStdout(t, func() {
+		if err := Run(cmd); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "No secrets found") {
+		t.Errorf("second run output = %q, want nothing moved", output)
+	}
+
+	// The split can be undone like any other change
+	if err := runHistory(&HistoryCommandOptions{Action: "undo"}); err != nil {
+		t.Fatalf("undo error = %v", err)
+	}
+	if _, err := os.Stat(config.GetSecretsPath()); !os.IsNotExist(err) {
+		t.Errorf("secrets file still exists after undo: %v", err)
+	}
+}
+
+func TestRunSecretsEncrypt(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+	t.Setenv(config.PassphraseEnv, "hunter2")
+
+	if err := config.Save(&config.Config{
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": "sk-glm-plain"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// An earlier snapshot holds the token in plain text
+	if _, err := config.TakeSnapshot("switch to glm"); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() {
+		if err := runSecrets(parseSecretsArgs([]string{"encrypt"})); err != nil {
+			t.Errorf("encrypt error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Encrypted 1 secret(s)") || !strings.Contains(output, "in 1 snapshot(s)") {
+		t.Errorf("output = %q, want the encrypted token", output)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := config.GetAuthToken(cfg.Providers["glm"])
+	if !config.IsEncrypted(token) {
+		t.Errorf("token = %q, want an encrypted value", token)
+	}
+
+	// No snapshot is taken, and the history no longer holds the plain text
+	snapshots, err := config.ListSnapshots()
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("ListSnapshots() = %d snapshots, %v; want 1", len(snapshots), err)
+	}
+	data, _, err := config.ReadSnapshotFile(snapshots[0], "ccc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "sk-glm-plain") || !strings.Contains(string(data), token) {
+		t.Errorf("snapshot ccc.json = %s, want the encrypted token", data)
+	}
+
+	// Values already encrypted with another passphrase are not mixed
+	t.Setenv(config.PassphraseEnv, "other")
+	if err := runSecrets(parseSecretsArgs([]string{"encrypt"})); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("encrypt with another passphrase error = %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	if got, want := shellQuote(`it's $x`), `'it'\''s $x'`; got != want {
+		t.Errorf("shellQuote() = %s, want %s", got, want)
+	}
+}
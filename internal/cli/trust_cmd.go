@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// confirmTrustFunc asks on the terminal whether to trust a project file.
+// It returns false without asking when there is no terminal.
+// This variable allows tests to answer the prompt.
+var confirmTrustFunc = func(prompt string) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	line, _ := bufio.NewReader(tty).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// describeProject lists what a project file changes besides the provider.
+func describeProject(project *config.ProjectConfig) string {
+	var b strings.Builder
+	if len(project.Settings) > 0 {
+		keys := make([]string, 0, len(project.Settings))
+		for key := range project.Settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(&b, "  settings: %s\n", strings.Join(keys, ", "))
+	}
+	if len(project.ClaudeArgs) > 0 {
+		fmt.Fprintf(&b, "  claude_args: %s\n", strings.Join(project.ClaudeArgs, " "))
+	}
+	return b.String()
+}
+
+// checkProjectTrust returns the part of project to apply: all of it if the
+// user trusts the file, asking on the terminal the first time, otherwise only
+// its provider pin.
+func checkProjectTrust(project *config.ProjectConfig) (*config.ProjectConfig, error) {
+	trusted, err := config.IsProjectTrusted(project)
+	if err != nil {
+		return nil, err
+	}
+	if trusted {
+		return project, nil
+	}
+
+	prompt := fmt.Sprintf("The project file %s changes how claude runs:\n%sTrust it? [y/N] ",
+		project.Path, describeProject(project))
+	if confirmTrustFunc(prompt) {
+		if err := trustProject(project); err != nil {
+			return nil, err
+		}
+		return project, nil
+	}
+	fmt.Fprintf(os.Stderr, "Warning: ignoring the settings and claude_args of the untrusted project file %s; run `ccc trust` to apply them\n",
+		project.Path)
+	return project.Untrusted(), nil
+}
+
+// trustProject records project as trusted while holding the config lock.
+func trustProject(project *config.ProjectConfig) error {
+	unlock, err := config.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return config.TrustProject(project)
+}
+
+// runTrust executes the trust command: it trusts the project file of the
+// current directory tree with its current content.
+func runTrust() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	project, err := config.FindProjectConfig(cwd)
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("no %s found in %s or its parents", config.ProjectFileName, cwd)
+	}
+	if err := trustProject(project); err != nil {
+		return err
+	}
+	fmt.Printf("Trusted %s\n%s", project.Path, describeProject(project))
+	return nil
+}
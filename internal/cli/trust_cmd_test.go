@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestCheckProjectTrust(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), config.ProjectFileName)
+	content := `{"provider": "glm", "settings": {"hooks": {}}, "claude_args": ["--dangerously-skip-permissions"]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := config.LoadProjectConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompts []string
+	answer := false
+	original := confirmTrustFunc
+	confirmTrustFunc = func(prompt string) bool {
+		prompts = append(prompts, prompt)
+		return answer
+	}
+	defer func() { confirmTrustFunc = original }()
+
+	// Declined: only the provider pin applies
+	applied, err := checkProjectTrust(project)
+	if err != nil {
+		t.Fatalf("checkProjectTrust() error = %v", err)
+	}
+	if applied.Provider != "glm" || applied.Settings != nil || applied.ClaudeArgs != nil {
+		t.Errorf("checkProjectTrust() = %+v, want the provider pin only", applied)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "claude_args: --dangerously-skip-permissions") {
+		t.Errorf("prompts = %q, want the claude_args listed", prompts)
+	}
+
+	// Accepted once: applied and not asked again
+	answer = true
+	for i := 0; i < 2; i++ {
+		if applied, err = checkProjectTrust(project); err != nil || applied != project {
+			t.Fatalf("checkProjectTrust() = %+v, %v, want the whole project", applied, err)
+		}
+	}
+	if len(prompts) != 2 {
+		t.Errorf("asked %d times, want the trusted file not to be asked about again", len(prompts))
+	}
+}
+
+func TestRunTrust(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if cmd := Parse([]string{"trust"}); !cmd.Trust {
+		t.Fatal("Parse(trust) should set Trust")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.ProjectFileName)
+	if err := os.WriteFile(path, []byte(`{"claude_args": ["--verbose"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	output := captureStdout(t, func() {
+		if err := runTrust(); err != nil {
+			t.Errorf("runTrust() error = %v", err)
+		}
+	})
+	if !strings.Contains(output, "Trusted ") {
+		t.Errorf("output = %q", output)
+	}
+	project, err := config.FindProjectConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trusted, err := config.IsProjectTrusted(project); err != nil || !trusted {
+		t.Errorf("IsProjectTrusted() = %v, %v after ccc trust", trusted, err)
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ccc.json")
+
+	t.Run("creates and replaces the file keeping its mode", func(t *testing.T) {
+		if err := WriteFileAtomic(path, []byte("first"), 0600); err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+		if err := WriteFileAtomic(path, []byte("second"), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic() error = %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "second" {
+			t.Errorf("content = %q, want second", data)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+		}
+	})
+
+	t.Run("leaves no temp files behind", func(t *testing.T) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("directory has %d entries, want only ccc.json", len(entries))
+		}
+	})
+
+	t.Run("failure keeps the old content", func(t *testing.T) {
+		// Renaming over a directory fails after the temp file was written
+		target := filepath.Join(dir, "settings.json")
+		if err := os.Mkdir(target, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFileAtomic(target, []byte("x"), 0644); err == nil {
+			t.Fatal("WriteFileAtomic() over a directory should fail")
+		}
+
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 2 {
+			t.Errorf("directory has %d entries, want the temp file removed", len(entries))
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "second" {
+			t.Errorf("unrelated file changed: %q", data)
+		}
+	})
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	dotfiles := filepath.Join(dir, "dotfiles")
+	if err := os.Mkdir(dotfiles, 0700); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dotfiles, "settings.json")
+	if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+	}{
+		{"absolute link", filepath.Join(dir, "settings.json"), target},
+		{"relative link", filepath.Join(dir, "relative.json"), "dotfiles/settings.json"},
+		{"link to a link", filepath.Join(dir, "chained.json"), filepath.Join(dir, "settings.json")},
+		{"dangling link", filepath.Join(dir, "ccc.json"), filepath.Join(dotfiles, "ccc.json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.Symlink(tt.target, tt.link); err != nil {
+				t.Fatal(err)
+			}
+			if err := WriteFileAtomic(tt.link, []byte(tt.name), 0600); err != nil {
+				t.Fatalf("WriteFileAtomic() error = %v", err)
+			}
+
+			info, err := os.Lstat(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode()&os.ModeSymlink == 0 {
+				t.Error("symlink was replaced by a regular file")
+			}
+			data, err := os.ReadFile(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.name {
+				t.Errorf("content = %q, want %q", data, tt.name)
+			}
+		})
+	}
+
+	// Temp files are created next to the target, not the link
+	entries, err := os.ReadDir(dotfiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("dotfiles has %d entries, want settings.json and ccc.json", len(entries))
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ClaudeArgsKey is the provider key holding arguments passed to claude when
+// the provider is used. Like extends, it never reaches settings.json.
+const ClaudeArgsKey = "claude_args"
+
+// ProviderClaudeArgs returns the claude_args of a provider, resolved through
+// its extends chain: a child's list replaces its parent's.
+func ProviderClaudeArgs(cfg *Config, name string) ([]string, error) {
+	resolved, err := mergeChain(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	value, exists := resolved[ClaudeArgsKey]
+	if !exists || value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("provider '%s': claude_args must be a list of strings", name)
+	}
+	args := make([]string, 0, len(list))
+	for _, item := range list {
+		arg, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("provider '%s': claude_args must be a list of strings", name)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// CombineClaudeArgs returns the arguments passed to claude, in order: the
+// global claude_args, the provider's, the project file's, then the command
+// line. In provider and project args, "!<flag>" adds nothing but drops <flag>
+// from the earlier layers, together with its value: a following argument
+// that doesn't start with "-", or the part after "=" in "<flag>=<value>".
+func CombineClaudeArgs(global, provider, project, commandLine []string) []string {
+	args := append([]string{}, global...)
+	for _, layer := range [][]string{provider, project} {
+		var added []string
+		for _, arg := range layer {
+			if flag, ok := strings.CutPrefix(arg, "!"); ok {
+				args = dropArg(args, flag)
+				continue
+			}
+			added = append(added, arg)
+		}
+		args = append(args, added...)
+	}
+	return append(args, commandLine...)
+}
+
+// dropArg removes every occurrence of flag and its value from args.
+func dropArg(args []string, flag string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == flag:
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				i++
+			}
+		case strings.HasPrefix(args[i], flag+"="):
+		default:
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
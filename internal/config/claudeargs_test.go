@@ -0,0 +1,102 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProviderClaudeArgs(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]map[string]interface{}{
+			"glm":      {"claude_args": []interface{}{"--model", "glm-4.7"}},
+			"glm-air":  {"extends": "glm"},
+			"glm-fast": {"extends": "glm", "claude_args": []interface{}{"!--model"}},
+			"kimi":     {},
+			"broken":   {"claude_args": "--verbose"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"glm", []string{"--model", "glm-4.7"}},
+		{"glm-air", []string{"--model", "glm-4.7"}},
+		{"glm-fast", []string{"!--model"}},
+		{"kimi", nil},
+	}
+	for _, tt := range tests {
+		got, err := ProviderClaudeArgs(cfg, tt.name)
+		if err != nil {
+			t.Fatalf("ProviderClaudeArgs(%s) error = %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ProviderClaudeArgs(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := ProviderClaudeArgs(cfg, "broken"); err == nil || !strings.Contains(err.Error(), "must be a list of strings") {
+		t.Errorf("ProviderClaudeArgs(broken) error = %v", err)
+	}
+
+	// claude_args never reaches settings.json
+	resolved, err := ResolveProvider(cfg, "glm-air")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := resolved[ClaudeArgsKey]; exists {
+		t.Error("claude_args must be removed from the resolved provider")
+	}
+}
+
+func TestCombineClaudeArgs(t *testing.T) {
+	tests := []struct {
+		name                   string
+		global, provider, proj []string
+		commandLine            []string
+		want                   []string
+	}{
+		{
+			name:        "layers in order",
+			global:      []string{"--verbose"},
+			provider:    []string{"--model", "glm-4.7"},
+			proj:        []string{"--add-dir", "../shared"},
+			commandLine: []string{"-p", "hi"},
+			want:        []string{"--verbose", "--model", "glm-4.7", "--add-dir", "../shared", "-p", "hi"},
+		},
+		{
+			name:     "drop a flag with its value",
+			global:   []string{"--model", "opus", "--verbose"},
+			provider: []string{"!--model", "--model", "glm-4.7"},
+			want:     []string{"--verbose", "--model", "glm-4.7"},
+		},
+		{
+			name:     "drop a flag=value",
+			global:   []string{"--permission-mode=plan", "--verbose"},
+			provider: []string{"!--permission-mode"},
+			want:     []string{"--verbose"},
+		},
+		{
+			name:   "drop a boolean flag",
+			global: []string{"--verbose", "--debug"},
+			proj:   []string{"!--verbose"},
+			want:   []string{"--debug"},
+		},
+		{
+			name:        "command line is never dropped",
+			global:      []string{"--verbose"},
+			provider:    []string{"!--verbose"},
+			commandLine: []string{"--verbose"},
+			want:        []string{"--verbose"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CombineClaudeArgs(tt.global, tt.provider, tt.proj, tt.commandLine)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CombineClaudeArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
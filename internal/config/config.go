@@ -3,11 +3,16 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/guyskk/ccc/internal/jsonc"
+	"github.com/guyskk/ccc/internal/prettyjson"
 )
 
 // GetDirFunc is a function that returns the Claude configuration directory.
@@ -32,10 +37,54 @@ func GetDir() string {
 // Config represents the ccc.json configuration structure.
 // Settings and Providers use dynamic maps to handle arbitrary Claude settings fields.
 type Config struct {
+	Schema          string                            `json:"$schema,omitempty"`
+	Version         int                               `json:"version,omitempty"`
 	Settings        map[string]interface{}            `json:"settings"`
 	ClaudeArgs      []string                          `json:"claude_args,omitempty"`
-	CurrentProvider string                            `json:"current_provider"`
+	DefaultProvider string                            `json:"default_provider,omitempty"`
+	CurrentProvider string                            `json:"current_provider,omitempty"`
+	Order           []string                          `json:"order,omitempty"`
+	Merge           map[string]string                 `json:"merge,omitempty"`
 	Providers       map[string]map[string]interface{} `json:"providers"`
+
+	// Project is the project file found for the current directory, if any.
+	// It is loaded separately and never written to ccc.json.
+	Project *ProjectConfig `json:"-"`
+
+	// Fragments are the files of ccc.d merged under ccc.json, in the order
+	// they were applied. They are never written to ccc.json.
+	Fragments []*Fragment `json:"-"`
+
+	// Secrets is the content of the secrets file merged on top of ccc.json,
+	// or nil if there is no secrets file.
+	Secrets map[string]interface{} `json:"-"`
+}
+
+// ProviderNames returns the provider names in display order: the names listed
+// in Order first (unknown names and duplicates are skipped), followed by the
+// remaining providers sorted alphabetically.
+func ProviderNames(cfg *Config) []string {
+	if cfg == nil || len(cfg.Providers) == 0 {
+		return []string{}
+	}
+
+	names := make([]string, 0, len(cfg.Providers))
+	listed := make(map[string]bool)
+	for _, name := range cfg.Order {
+		if _, exists := cfg.Providers[name]; exists && !listed[name] {
+			names = append(names, name)
+			listed[name] = true
+		}
+	}
+
+	var rest []string
+	for name := range cfg.Providers {
+		if !listed[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
 }
 
 // GetConfigPath returns the path to ccc.json.
@@ -48,66 +97,184 @@ func GetSettingsPath() string {
 	return filepath.Join(GetDir(), "settings.json")
 }
 
-// Load reads and parses the ccc.json configuration file.
+// GetStateDir returns the directory where ccc keeps its own state files.
+func GetStateDir() string {
+	return filepath.Join(GetDir(), "ccc")
+}
+
+// GetSessionDir returns the directory holding per-session settings files.
+func GetSessionDir() string {
+	return filepath.Join(GetStateDir(), "sessions")
+}
+
+// Load reads and parses the ccc.json configuration file, deep-merged on top
+// of the fragments in ccc.d (see Fragment) and under the secrets file. The
+// files may contain comments and trailing commas (JSONC). ccc.json may be
+// missing if there are fragments.
 func Load() (*Config, error) {
-	configPath := GetConfigPath()
-	data, err := os.ReadFile(configPath)
+	fragments, err := loadFragments()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	configPath := GetConfigPath()
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		if !os.IsNotExist(err) || len(fragments) == 0 {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		raw = []byte("{}")
+	}
+	tree, err := parseConfigFile(configPath, raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(fragments) > 0 {
+		tree = DeepMerge(mergeFragments(fragments), tree)
+	}
+	secrets, err := loadSecrets()
+	if err != nil {
+		return nil, err
+	}
+	if secrets != nil {
+		if err := checkSecretProviders(secrets, tree); err != nil {
+			return nil, err
+		}
+		tree = DeepMerge(tree, secrets)
 	}
 
-	return &cfg, nil
+	cfg, err := FromMap(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge config fragments: %w", err)
+	}
+	cfg.Fragments = fragments
+	cfg.Secrets = secrets
+	return cfg, nil
 }
 
-// Save writes the configuration to ccc.json.
+// Save writes the configuration to ccc.json, atomically replacing the file.
+// Only the values that changed are rewritten, so comments and formatting in
+// the existing file are kept. Values that come from the fragments in ccc.d
+// are not copied into ccc.json, and when there is a secrets file, the secret
+// env values of providers are written there instead (see splitSecretValues).
 func Save(cfg *Config) error {
 	configPath := GetConfigPath()
 
 	// Ensure config directory exists
 	configDir := filepath.Dir(configPath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
+	existing, readErr := os.ReadFile(configPath)
+	var content interface{} = cfg
+	if len(cfg.Fragments) > 0 || cfg.Secrets != nil {
+		var previous map[string]interface{}
+		if readErr == nil {
+			// An unreadable file is replaced below anyway
+			_ = json.Unmarshal(jsonc.Strip(existing), &previous)
+		}
+		tree, err := ToMap(cfg)
+		if err != nil {
+			return err
+		}
+		base, err := fragmentBase(cfg)
+		if err != nil {
+			return err
+		}
+		// Secrets are written first, so they are never lost if writing
+		// ccc.json fails
+		if cfg.Secrets != nil {
+			if err := saveSecrets(splitSecretValues(tree, cfg.Secrets, previous, base)); err != nil {
+				return err
+			}
+		}
+		if tree, err = diffLayer(tree, base, previous, nil, cfg.Fragments); err != nil {
+			return err
+		}
+		content = tree
+	}
+
+	data, err := json.MarshalIndent(content, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	// Patch the existing file; if it can't be parsed, it is replaced entirely
+	if readErr == nil {
+		updated, err := withoutNullFields(data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config: %w", err)
+		}
+		if patched, err := jsonc.Patch(existing, updated); err == nil {
+			if bytes.Equal(patched, existing) {
+				return nil
+			}
+			data = patched
+		}
+	}
+
+	// ccc.json holds API tokens, so it is created private to the user
+	if err := WriteFileAtomic(configPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
 	return nil
 }
 
-// SaveSettings writes the settings to settings.json.
+// withoutNullFields removes the top-level members of a marshaled Config that
+// are null, i.e. unset fields like settings or providers, so patching doesn't
+// add them to ccc.json. Nulls deeper in the tree are deletion markers and kept.
+func withoutNullFields(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		if string(value) == "null" {
+			delete(fields, key)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// SaveSettings writes the settings to settings.json, atomically replacing the file.
+// The existing file's key order and indentation are kept, and the file is not
+// written at all if its content is already equal to settings.
 func SaveSettings(settings map[string]interface{}) error {
 	settingsPath := GetSettingsPath()
 
+	existing, err := os.ReadFile(settingsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read settings file: %w", err)
+	}
+	if err == nil && prettyjson.Equal(existing, settings) {
+		return nil
+	}
+
 	// Ensure settings directory exists
 	settingsDir := filepath.Dir(settingsPath)
-	if err := os.MkdirAll(settingsDir, 0755); err != nil {
+	if err := os.MkdirAll(settingsDir, 0700); err != nil {
 		return fmt.Errorf("failed to create settings directory: %w", err)
 	}
 
-	data, err := json.MarshalIndent(settings, "", "  ")
+	data, err := prettyjson.MarshalLike(settings, existing)
 	if err != nil {
 		return fmt.Errorf("failed to marshal settings: %w", err)
 	}
 
-	if err := os.WriteFile(settingsPath, data, 0644); err != nil {
+	if err := WriteFileAtomic(settingsPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write settings file: %w", err)
 	}
 
 	return nil
 }
 
+// DeepCopy creates a deep copy of a settings map.
+func DeepCopy(original map[string]interface{}) map[string]interface{} {
+	return deepCopy(original)
+}
+
 // deepCopy creates a deep copy of a map[string]interface{}.
 func deepCopy(original map[string]interface{}) map[string]interface{} {
 	if original == nil {
@@ -149,29 +316,11 @@ func deepCopySlice(original []interface{}) []interface{} {
 }
 
 // DeepMerge recursively merges provider settings into base settings.
-// Provider settings override base settings for the same keys.
+// Provider settings override base settings for the same keys; arrays are
+// replaced as a whole (see DeepMergeWith for other strategies).
 // This function handles arbitrary Claude settings fields.
 func DeepMerge(base, provider map[string]interface{}) map[string]interface{} {
-	result := deepCopy(base)
-	if result == nil {
-		result = make(map[string]interface{})
-	}
-
-	for key, value := range provider {
-		if existingVal, exists := result[key]; exists {
-			// If both are maps, merge them recursively
-			if existingMap, ok := existingVal.(map[string]interface{}); ok {
-				if newMap, ok := value.(map[string]interface{}); ok {
-					result[key] = DeepMerge(existingMap, newMap)
-					continue
-				}
-			}
-		}
-		// Otherwise, override with provider value
-		result[key] = value
-	}
-
-	return result
+	return deepMerge(base, provider, nil, "", false)
 }
 
 // GetEnv extracts the env map from settings.
@@ -295,8 +444,11 @@ func MergeEnvMaps(maps ...map[string]interface{}) map[string]interface{} {
 //  2. providerSettings (provider-specific config)
 //  3. baseSettings (ccc.json settings - template)
 //
-// Returns a new merged map without modifying the inputs.
-func MergeWithPriority(baseSettings, providerSettings, userSettings map[string]interface{}) map[string]interface{} {
+// Arrays are combined according to strategies (the merge field of ccc.json),
+// see DeepMergeWith. Provider settings are a merge patch: a null removes the
+// key from the base settings (see MergePatch). Returns a new merged map
+// without modifying the inputs.
+func MergeWithPriority(baseSettings, providerSettings, userSettings map[string]interface{}, strategies map[string]string) map[string]interface{} {
 	// Start with deep copy of base settings
 	result := deepCopy(baseSettings)
 	if result == nil {
@@ -304,10 +456,10 @@ func MergeWithPriority(baseSettings, providerSettings, userSettings map[string]i
 	}
 
 	// Merge provider settings into result (provider overrides base)
-	result = DeepMerge(result, providerSettings)
+	result = MergePatch(result, providerSettings, strategies)
 
 	// Merge user settings into result (user overrides all)
-	result = DeepMerge(result, userSettings)
+	result = DeepMergeWith(result, userSettings, strategies)
 
 	return result
 }
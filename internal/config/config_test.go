@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // setupTestDir creates a temporary directory for testing.
@@ -100,6 +101,34 @@ func TestGetSettingsPath(t *testing.T) {
 	}
 }
 
+func TestProviderNames(t *testing.T) {
+	providers := map[string]map[string]interface{}{
+		"kimi": {}, "glm": {}, "minimax": {}, "88code": {},
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{"sorted without order", nil, []string{"88code", "glm", "kimi", "minimax"}},
+		{"order first, rest sorted", []string{"minimax", "kimi"}, []string{"minimax", "kimi", "88code", "glm"}},
+		{"unknown and duplicate names skipped", []string{"kimi", "gone", "kimi"}, []string{"kimi", "88code", "glm", "minimax"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProviderNames(&Config{Order: tt.order, Providers: providers})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProviderNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := ProviderNames(nil); len(got) != 0 {
+		t.Errorf("ProviderNames(nil) = %v, want empty", got)
+	}
+}
+
 func TestLoad(t *testing.T) {
 	t.Run("valid config", func(t *testing.T) {
 		tmpDir, cleanup := setupTestDir(t)
@@ -239,6 +268,88 @@ func TestSave(t *testing.T) {
 	})
 }
 
+func TestLoadAndSaveJSONC(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	content := `{
+  // Team gateways
+  "providers": {
+    "glm": { "env": { "ANTHROPIC_MODEL": "glm-4.7" } }, // expires in March
+    "kimi": {},
+  },
+  "current_provider": "glm", /* switched by ccc */
+}
+`
+	if err := os.WriteFile(GetConfigPath(), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.Providers) != 2 || cfg.CurrentProvider != "glm" {
+		t.Fatalf("Load() = %+v, want 2 providers and glm current", cfg)
+	}
+
+	cfg.CurrentProvider = "kimi"
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(content, `"current_provider": "glm"`, `"current_provider": "kimi"`, 1)
+	if string(data) != want {
+		t.Errorf("ccc.json after Save() =\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestSaveKeepsNulls(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := &Config{
+		Settings: map[string]interface{}{"model": "opus"},
+		Providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.7"}},
+		},
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A null added to an existing file is patched in
+	cfg.Providers["glm"]["model"] = nil
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if value, exists := loaded.Providers["glm"]["model"]; !exists || value != nil {
+		t.Errorf("providers.glm.model = %v (exists %v), want null", value, exists)
+	}
+	if merged := MergeWithPriority(loaded.Settings, loaded.Providers["glm"], nil, nil); merged["model"] != nil {
+		t.Errorf("merged model = %v, want deleted by the null", merged["model"])
+	}
+
+	// Unset fields of Config are not added as nulls
+	loaded.Settings = nil
+	if err := Save(loaded); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"settings"`) {
+		t.Errorf("ccc.json = %s, want no settings member", data)
+	}
+}
+
 func TestSaveSettings(t *testing.T) {
 	_, cleanup := setupTestDir(t)
 	defer cleanup()
@@ -272,6 +383,51 @@ func TestSaveSettings(t *testing.T) {
 	}
 }
 
+func TestSaveSettingsPreservesFormat(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	settingsPath := GetSettingsPath()
+	if err := os.MkdirAll(filepath.Dir(settingsPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original := "{\n    \"theme\": \"dark\",\n    \"hooks\": {\n        \"Stop\": \"make lint && make test\"\n    },\n    \"env\": {}\n}\n"
+	if err := os.WriteFile(settingsPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged content: the file is not rewritten
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(settingsPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSettings(settings); err != nil {
+		t.Fatalf("SaveSettings() error = %v", err)
+	}
+	if info, _ := os.Stat(settingsPath); !info.ModTime().Equal(past) {
+		t.Error("SaveSettings() should not write unchanged settings")
+	}
+
+	// Changed content: key order, indentation and && are kept, new keys go last
+	settings["env"] = map[string]interface{}{"ANTHROPIC_MODEL": "glm"}
+	settings["alwaysThinkingEnabled"] = true
+	if err := SaveSettings(settings); err != nil {
+		t.Fatalf("SaveSettings() error = %v", err)
+	}
+	data, err := os.ReadFile(settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n    \"theme\": \"dark\",\n    \"hooks\": {\n        \"Stop\": \"make lint && make test\"\n    },\n    \"env\": {\n        \"ANTHROPIC_MODEL\": \"glm\"\n    },\n    \"alwaysThinkingEnabled\": true\n}\n"
+	if string(data) != want {
+		t.Errorf("settings.json =\n%s\nwant:\n%s", data, want)
+	}
+}
+
 func TestDeepCopy(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -967,7 +1123,7 @@ func TestMergeWithPriority(t *testing.T) {
 			"field4": "user",
 		}
 
-		result := MergeWithPriority(baseSettings, providerSettings, userSettings)
+		result := MergeWithPriority(baseSettings, providerSettings, userSettings, nil)
 
 		if result["field1"] != "user" {
 			t.Errorf("field1 should be 'user' (userSettings highest priority), got: %v", result["field1"])
@@ -992,7 +1148,7 @@ func TestMergeWithPriority(t *testing.T) {
 		}
 		userSettings := map[string]interface{}(nil)
 
-		result := MergeWithPriority(baseSettings, providerSettings, userSettings)
+		result := MergeWithPriority(baseSettings, providerSettings, userSettings, nil)
 
 		if result["field1"] != "provider" {
 			t.Errorf("field1 should be 'provider', got: %v", result["field1"])
@@ -1018,7 +1174,7 @@ func TestMergeWithPriority(t *testing.T) {
 			},
 		}
 
-		result := MergeWithPriority(baseSettings, providerSettings, userSettings)
+		result := MergeWithPriority(baseSettings, providerSettings, userSettings, nil)
 
 		nested := result["nested"].(map[string]interface{})
 		if nested["a"] != "base-a" {
@@ -1033,7 +1189,7 @@ func TestMergeWithPriority(t *testing.T) {
 	})
 
 	t.Run("all nil returns empty map", func(t *testing.T) {
-		result := MergeWithPriority(nil, nil, nil)
+		result := MergeWithPriority(nil, nil, nil, nil)
 
 		if result == nil || len(result) != 0 {
 			t.Errorf("result should be empty map, got: %v", result)
@@ -1045,7 +1201,7 @@ func TestMergeWithPriority(t *testing.T) {
 		providerSettings := map[string]interface{}{"b": "provider"}
 		userSettings := map[string]interface{}{"c": "user"}
 
-		result := MergeWithPriority(baseSettings, providerSettings, userSettings)
+		result := MergeWithPriority(baseSettings, providerSettings, userSettings, nil)
 
 		// Modify result should not affect inputs
 		result["newKey"] = "new"
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EncryptedPrefix marks an env value encrypted with a passphrase. The rest of
+// the value is base64 of salt, nonce and the AES-256-GCM ciphertext; the key
+// is derived from the passphrase and salt with PBKDF2-SHA256.
+const EncryptedPrefix = "enc:v1:"
+
+// PassphraseEnv is the environment variable holding the passphrase of
+// encrypted values. If it is not set, ccc asks for the passphrase on the
+// terminal.
+const PassphraseEnv = "CCC_PASSPHRASE"
+
+const (
+	saltSize  = 16
+	nonceSize = 12
+)
+
+// kdfIterations is the PBKDF2 iteration count of enc:v1: values.
+// Tests lower it to keep key derivation fast.
+var kdfIterations = 600000
+
+var (
+	keyCacheMu sync.Mutex
+	// keyCache holds derived keys by passphrase and salt, so values encrypted
+	// together only pay for one key derivation
+	keyCache = make(map[string][]byte)
+
+	passphraseMu sync.Mutex
+	// promptedPassphrase is the passphrase entered on the terminal, asked for
+	// at most once per run
+	promptedPassphrase string
+)
+
+// IsEncrypted reports whether value is encrypted (see EncryptedPrefix).
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// deriveKey returns the AES key for passphrase and salt.
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	keyCacheMu.Lock()
+	defer keyCacheMu.Unlock()
+	id := passphrase + "\x00" + string(salt)
+	if key, ok := keyCache[id]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	keyCache[id] = key
+	return key, nil
+}
+
+// newGCM returns the cipher for passphrase and salt.
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptWithSalt encrypts plaintext with the key for passphrase and salt.
+func encryptWithSalt(plaintext, passphrase string, salt []byte) (string, error) {
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	blob := append(append(append([]byte{}, salt...), nonce...), gcm.Seal(nil, nonce, []byte(plaintext), nil)...)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// newSalt returns a random salt.
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// EncryptSecret encrypts plaintext with passphrase.
+func EncryptSecret(plaintext, passphrase string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	return encryptWithSalt(plaintext, passphrase, salt)
+}
+
+// DecryptSecret decrypts a value made by EncryptSecret. Errors never contain
+// the passphrase or the decrypted value.
+func DecryptSecret(value, passphrase string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil || len(blob) < saltSize+nonceSize {
+		return "", errors.New("malformed encrypted value")
+	}
+	salt, nonce, ciphertext := blob[:saltSize], blob[saltSize:saltSize+nonceSize], blob[saltSize+nonceSize:]
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("wrong passphrase or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+// Passphrase returns the passphrase of encrypted values: the value of
+// PassphraseEnv if set, otherwise one asked for on the terminal (only once per
+// run).
+func Passphrase() (string, error) {
+	if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+		return passphrase, nil
+	}
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if promptedPassphrase == "" {
+		passphrase, err := PromptPassphrase("Passphrase for encrypted secrets: ")
+		if err != nil {
+			return "", err
+		}
+		promptedPassphrase = passphrase
+	}
+	return promptedPassphrase, nil
+}
+
+// PromptPassphrase asks for a passphrase on the terminal without echoing it.
+func PromptPassphrase(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the passphrase; set %s", PassphraseEnv)
+	}
+	defer tty.Close()
+
+	stty := func(arg string) error {
+		c := exec.Command("stty", arg)
+		c.Stdin = tty
+		return c.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		return "", fmt.Errorf("failed to disable terminal echo: %w", err)
+	}
+	defer func() {
+		_ = stty("echo")
+		fmt.Fprintln(tty)
+	}()
+
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", errors.New("empty passphrase")
+	}
+	return passphrase, nil
+}
+
+// CheckPassphrase returns an error if passphrase doesn't decrypt the
+// encrypted values of cfg, so values are never encrypted with two different
+// passphrases.
+func CheckPassphrase(cfg *Config, passphrase string) error {
+	for _, name := range ProviderNames(cfg) {
+		for key, v := range GetEnv(cfg.Providers[name]) {
+			if s, ok := v.(string); ok && IsEncrypted(s) {
+				if _, err := DecryptSecret(s, passphrase); err != nil {
+					return fmt.Errorf("providers.%s.env.%s: %w", name, key, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EncryptSecrets encrypts with passphrase the secret env values (see
+// IsSecretKey) of the providers in ccc.json and the secrets file, in place.
+// References, values containing "$" and values from fragments are left
+// alone. Returns the paths of the encrypted values, sorted.
+func EncryptSecrets(cfg *Config, passphrase string) ([]string, error) {
+	main, err := readMainTree()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted []string
+	for _, name := range ProviderNames(cfg) {
+		env := GetEnv(cfg.Providers[name])
+		for key, v := range env {
+			value, ok := v.(string)
+			if !ok || value == "" || !IsSecretKey(key) || IsSecretRef(value) || strings.Contains(value, "$") {
+				continue
+			}
+			path := []string{"providers", name, "env", key}
+			_, inMain := GetPath(main, path)
+			_, inSecrets := GetPath(cfg.Secrets, path)
+			if !inMain && !inSecrets {
+				continue
+			}
+			if env[key], err = encryptWithSalt(value, passphrase, salt); err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s: %w", strings.Join(path, "."), err)
+			}
+			encrypted = append(encrypted, strings.Join(path, "."))
+		}
+	}
+	sort.Strings(encrypted)
+	return encrypted, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// fastKDF lowers the key derivation cost for the duration of a test.
+func fastKDF(t *testing.T) {
+	t.Helper()
+	old := kdfIterations
+	kdfIterations = 1000
+	t.Cleanup(func() { kdfIterations = old })
+}
+
+func TestEncryptSecret(t *testing.T) {
+	fastKDF(t)
+
+	encrypted, err := EncryptSecret("sk-secret", "hunter2")
+	if err != nil {
+		t.Fatalf("EncryptSecret() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "sk-secret") {
+		t.Fatalf("EncryptSecret() = %q, want an enc:v1: value", encrypted)
+	}
+	if again, _ := EncryptSecret("sk-secret", "hunter2"); again == encrypted {
+		t.Error("EncryptSecret() should use a new salt and nonce each time")
+	}
+
+	if got, err := DecryptSecret(encrypted, "hunter2"); err != nil || got != "sk-secret" {
+		t.Errorf("DecryptSecret() = %q, %v, want sk-secret", got, err)
+	}
+	if _, err := DecryptSecret(encrypted, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("DecryptSecret() with a wrong passphrase error = %v", err)
+	}
+	if _, err := DecryptSecret(EncryptedPrefix+"!!", "hunter2"); err == nil {
+		t.Error("DecryptSecret() should reject a malformed value")
+	}
+}
+
+func TestResolveEncryptedEnv(t *testing.T) {
+	fastKDF(t)
+	encrypted, err := EncryptSecret("sk-secret", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(PassphraseEnv, "hunter2")
+	resolved, err := ResolveEnv(map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": encrypted})
+	if err != nil || resolved["ANTHROPIC_AUTH_TOKEN"] != "sk-secret" {
+		t.Errorf("ResolveEnv() = %v, %v, want the decrypted token", resolved, err)
+	}
+
+	t.Setenv(PassphraseEnv, "wrong")
+	_, err = ResolveEnv(map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": encrypted})
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("ResolveEnv() error = %v, want a wrong passphrase", err)
+	}
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+	fastKDF(t)
+
+	writeFragment(t, "team.json", `{"providers": {"team": {"env": {"ANTHROPIC_AUTH_TOKEN": "team-token"}}}}`)
+	main := `{
+  "providers": {
+    // personal
+    "glm": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-glm", "ANTHROPIC_MODEL": "glm-4.7"}},
+    "kimi": {"env": {"ANTHROPIC_AUTH_TOKEN": "env:KIMI_TOKEN", "ANTHROPIC_API_KEY": "${KIMI_KEY}"}}
+  }
+}`
+	if err := os.WriteFile(GetConfigPath(), []byte(main), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := EncryptSecrets(cfg, "hunter2")
+	if err != nil {
+		t.Fatalf("EncryptSecrets() error = %v", err)
+	}
+	if got := strings.Join(encrypted, " "); got != "providers.glm.env.ANTHROPIC_AUTH_TOKEN" {
+		t.Errorf("EncryptSecrets() = %s, want only the plain glm token", got)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(GetConfigPath())
+	if strings.Contains(string(data), "sk-glm") || !strings.Contains(string(data), "// personal") {
+		t.Errorf("ccc.json =\n%s\nwant the token encrypted and the comment kept", data)
+	}
+	if strings.Contains(string(data), "team") {
+		t.Errorf("ccc.json =\n%s\nwant the fragment token left alone", data)
+	}
+
+	// Launching decrypts in memory only: saving again keeps the ciphertext
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPassphrase(reloaded, "hunter2"); err != nil {
+		t.Errorf("CheckPassphrase() error = %v", err)
+	}
+	if err := CheckPassphrase(reloaded, "wrong"); err == nil {
+		t.Error("CheckPassphrase() with a wrong passphrase should fail")
+	}
+	t.Setenv(PassphraseEnv, "hunter2")
+	resolved, err := ResolveEnv(GetEnv(reloaded.Providers["glm"]))
+	if err != nil || resolved["ANTHROPIC_AUTH_TOKEN"] != "sk-glm" {
+		t.Errorf("ResolveEnv() = %v, %v, want the decrypted token", resolved, err)
+	}
+	reloaded.CurrentProvider = "glm"
+	if err := Save(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(GetConfigPath()); strings.Contains(string(data), "sk-glm") {
+		t.Errorf("Save() wrote the plain text token:\n%s", data)
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandEnvStrict expands shell-style variable references in s.
+// Unlike os.ExpandEnv, an undefined variable is an error instead of silently
+// becoming an empty string. Supported forms:
+//
+//	$VAR, ${VAR}        value of VAR; error if VAR is not set
+//	${VAR:-default}     default if VAR is unset or empty
+//	${VAR-default}      default if VAR is unset
+//	${VAR:?message}     error with message if VAR is unset or empty
+//	${VAR?message}      error with message if VAR is unset
+//	$$                  a literal "$"
+//
+// Defaults may themselves contain references. A "$" not followed by a name or
+// "{" is kept literally. lookup reports a variable's value and whether it is set.
+// Returns the expanded string and a description of every unresolved reference.
+func ExpandEnvStrict(s string, lookup func(string) (string, bool)) (string, []string) {
+	var b strings.Builder
+	var problems []string
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		next := s[i+1]
+		switch {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+
+		case next == '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				problems = append(problems, fmt.Sprintf("unterminated reference %q", s[i:]))
+				return b.String(), problems
+			}
+			value, problem := expandBraced(s[i+2:end], lookup)
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+			b.WriteString(value)
+			i = end
+
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			name := s[i+1 : j]
+			if value, ok := lookup(name); ok {
+				b.WriteString(value)
+			} else {
+				problems = append(problems, fmt.Sprintf("$%s is not set", name))
+			}
+			i = j - 1
+
+		default:
+			b.WriteByte('$')
+		}
+	}
+
+	return b.String(), problems
+}
+
+// expandBraced expands the inside of a ${...} reference.
+func expandBraced(expr string, lookup func(string) (string, bool)) (string, string) {
+	j := 0
+	for j < len(expr) && isNameChar(expr[j]) {
+		j++
+	}
+	name := expr[:j]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Sprintf("invalid reference ${%s}", expr)
+	}
+
+	value, set := lookup(name)
+	rest := expr[j:]
+
+	// ${VAR}
+	if rest == "" {
+		if !set {
+			return "", fmt.Sprintf("${%s} is not set", name)
+		}
+		return value, ""
+	}
+
+	// ${VAR:-x} ${VAR-x} ${VAR:?x} ${VAR?x}
+	checkEmpty := strings.HasPrefix(rest, ":")
+	op := strings.TrimPrefix(rest, ":")
+	if op == "" || (op[0] != '-' && op[0] != '?') {
+		return "", fmt.Sprintf("invalid reference ${%s}", expr)
+	}
+	arg := op[1:]
+	missing := !set || (checkEmpty && value == "")
+
+	if op[0] == '-' {
+		if !missing {
+			return value, ""
+		}
+		expanded, problems := ExpandEnvStrict(arg, lookup)
+		return expanded, strings.Join(problems, "; ")
+	}
+
+	if missing {
+		if arg == "" {
+			return "", fmt.Sprintf("${%s} is not set", name)
+		}
+		return "", fmt.Sprintf("${%s}: %s", name, arg)
+	}
+	return value, ""
+}
+
+// matchingBrace returns the index of the "}" closing a "${" whose content
+// starts at start, allowing nested ${...} in defaults. Returns -1 if unclosed.
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnvStrict(t *testing.T) {
+	vars := map[string]string{
+		"HOST":  "example.com",
+		"EMPTY": "",
+		"TOKEN": "sk-x",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name         string
+		in           string
+		want         string
+		wantProblems []string
+	}{
+		{name: "no references", in: "plain", want: "plain"},
+		{name: "braced", in: "https://${HOST}/api", want: "https://example.com/api"},
+		{name: "bare", in: "https://$HOST/api", want: "https://example.com/api"},
+		{name: "set but empty is fine", in: "[${EMPTY}]", want: "[]"},
+		{name: "escaped dollar", in: "a$$b", want: "a$b"},
+		{name: "lone dollar kept", in: "cost: 5$ or $1", want: "cost: 5$ or $1"},
+		{name: "default when unset", in: "${MISSING:-fallback}", want: "fallback"},
+		{name: "default when empty", in: "${EMPTY:-fallback}", want: "fallback"},
+		{name: "dash default keeps empty", in: "${EMPTY-fallback}", want: ""},
+		{name: "dash default when unset", in: "${MISSING-fallback}", want: "fallback"},
+		{name: "default not used when set", in: "${HOST:-fallback}", want: "example.com"},
+		{name: "nested default", in: "${MISSING:-https://${HOST}}", want: "https://example.com"},
+		{name: "required set", in: "${TOKEN:?set TOKEN}", want: "sk-x"},
+		{
+			name:         "undefined braced",
+			in:           "${GLM_TOKEN}",
+			wantProblems: []string{"${GLM_TOKEN} is not set"},
+		},
+		{
+			name:         "undefined bare",
+			in:           "$GLM_TOKEN",
+			wantProblems: []string{"$GLM_TOKEN is not set"},
+		},
+		{
+			name:         "required with message",
+			in:           "${EMPTY:?export EMPTY first}",
+			wantProblems: []string{"${EMPTY}: export EMPTY first"},
+		},
+		{
+			name:         "required without message",
+			in:           "${MISSING?}",
+			wantProblems: []string{"${MISSING} is not set"},
+		},
+		{
+			name:         "every problem is reported",
+			in:           "${A}/${B:?need B}/${HOST}",
+			want:         "//example.com",
+			wantProblems: []string{"${A} is not set", "${B}: need B"},
+		},
+		{
+			name:         "unresolved default",
+			in:           "${MISSING:-${ALSO_MISSING}}",
+			wantProblems: []string{"${ALSO_MISSING} is not set"},
+		},
+		{
+			name:         "unterminated",
+			in:           "${HOST",
+			wantProblems: []string{`unterminated reference "${HOST"`},
+		},
+		{
+			name:         "invalid operator",
+			in:           "${HOST:+x}",
+			wantProblems: []string{"invalid reference ${HOST:+x}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := ExpandEnvStrict(tt.in, lookup)
+			if !reflect.DeepEqual(problems, tt.wantProblems) {
+				t.Fatalf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandEnvStrict(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
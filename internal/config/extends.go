@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ExtendsKey is the provider key naming the provider it inherits from.
+const ExtendsKey = "extends"
+
+// ResolveProvider returns the fully resolved settings of a provider.
+// A provider with "extends": "<parent>" is deep-merged on top of its parent
+// (resolved the same way, so chains may be several levels deep). The extends
+// and claude_args keys are not settings and are removed from the result.
+// Returns an error if the provider or a parent does not exist, or if the
+// chain contains a cycle.
+func ResolveProvider(cfg *Config, name string) (map[string]interface{}, error) {
+	resolved, err := mergeChain(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+	delete(resolved, ExtendsKey)
+	delete(resolved, ClaudeArgsKey)
+	return resolved, nil
+}
+
+// mergeChain deep-merges a provider on top of its extends chain, keeping
+// every key.
+func mergeChain(cfg *Config, name string) (map[string]interface{}, error) {
+	chain, err := ProviderChain(cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge from root to child, so children override their parents
+	resolved := make(map[string]interface{})
+	for _, current := range chain {
+		resolved = DeepMerge(resolved, cfg.Providers[current])
+	}
+	return resolved, nil
+}
+
+// ProviderChain returns the extends chain of a provider, from the root to
+// the provider itself.
+func ProviderChain(cfg *Config, name string) ([]string, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if _, exists := cfg.Providers[name]; !exists {
+		return nil, fmt.Errorf("provider '%s' not found in configuration", name)
+	}
+
+	// Walk up the chain, collecting providers from child to root
+	var chain []string
+	seen := make(map[string]bool)
+	for current := name; current != ""; {
+		if seen[current] {
+			return nil, fmt.Errorf("provider '%s': extends cycle %s -> %s", name, strings.Join(chain, " -> "), current)
+		}
+		seen[current] = true
+		chain = append(chain, current)
+
+		settings := cfg.Providers[current]
+		parent, err := extendsOf(current, settings)
+		if err != nil {
+			return nil, err
+		}
+		if parent == "" {
+			break
+		}
+		if _, exists := cfg.Providers[parent]; !exists {
+			return nil, fmt.Errorf("provider '%s' extends unknown provider '%s'", current, parent)
+		}
+		current = parent
+	}
+
+	// Reverse to root first
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain, nil
+}
+
+// extendsOf returns the parent named by a provider's extends key, or "" if
+// the provider does not extend another one.
+func extendsOf(name string, settings map[string]interface{}) (string, error) {
+	value, exists := settings[ExtendsKey]
+	if !exists {
+		return "", nil
+	}
+	parent, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("provider '%s': extends must be a provider name", name)
+	}
+	return parent, nil
+}
+
+// ProvidersExtending returns the sorted names of providers that directly extend name.
+func ProvidersExtending(cfg *Config, name string) []string {
+	var children []string
+	for child, settings := range cfg.Providers {
+		if parent, _ := extendsOf(child, settings); parent == name {
+			children = append(children, child)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
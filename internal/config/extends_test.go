@@ -0,0 +1,150 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveProvider(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]map[string]interface{}{
+			"glm": {
+				"env": map[string]interface{}{
+					"ANTHROPIC_BASE_URL":   "https://open.bigmodel.cn/api/anthropic",
+					"ANTHROPIC_AUTH_TOKEN": "sk-glm",
+					"ANTHROPIC_MODEL":      "glm-4.7",
+				},
+			},
+			"glm-air": {
+				"extends": "glm",
+				"env":     map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.5-air"},
+			},
+			"glm-air-fast": {
+				"extends":               "glm-air",
+				"alwaysThinkingEnabled": false,
+			},
+		},
+	}
+
+	t.Run("plain provider is returned as is", func(t *testing.T) {
+		got, err := ResolveProvider(cfg, "glm")
+		if err != nil {
+			t.Fatalf("ResolveProvider() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, cfg.Providers["glm"]) {
+			t.Errorf("ResolveProvider() = %v, want %v", got, cfg.Providers["glm"])
+		}
+	})
+
+	t.Run("child overrides parent", func(t *testing.T) {
+		got, err := ResolveProvider(cfg, "glm-air")
+		if err != nil {
+			t.Fatalf("ResolveProvider() error = %v", err)
+		}
+		if GetModel(got) != "glm-4.5-air" {
+			t.Errorf("model = %q, want glm-4.5-air", GetModel(got))
+		}
+		if GetAuthToken(got) != "sk-glm" {
+			t.Errorf("token = %q, want inherited sk-glm", GetAuthToken(got))
+		}
+		if _, exists := got["extends"]; exists {
+			t.Error("extends must be removed from the resolved provider")
+		}
+	})
+
+	t.Run("multi-level chain", func(t *testing.T) {
+		got, err := ResolveProvider(cfg, "glm-air-fast")
+		if err != nil {
+			t.Fatalf("ResolveProvider() error = %v", err)
+		}
+		if GetModel(got) != "glm-4.5-air" || GetBaseURL(got) != "https://open.bigmodel.cn/api/anthropic" {
+			t.Errorf("resolved env = %v, want values from both ancestors", GetEnv(got))
+		}
+		if got["alwaysThinkingEnabled"] != false {
+			t.Error("own settings should be kept")
+		}
+	})
+
+	t.Run("does not modify the config", func(t *testing.T) {
+		got, _ := ResolveProvider(cfg, "glm-air")
+		GetEnv(got)["ANTHROPIC_MODEL"] = "changed"
+		if GetModel(cfg.Providers["glm"]) != "glm-4.7" || GetModel(cfg.Providers["glm-air"]) != "glm-4.5-air" {
+			t.Error("ResolveProvider() must not modify providers in the config")
+		}
+	})
+}
+
+func TestResolveProviderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers map[string]map[string]interface{}
+		resolve   string
+		wantErr   string
+	}{
+		{
+			name:      "unknown provider",
+			providers: map[string]map[string]interface{}{},
+			resolve:   "glm",
+			wantErr:   "provider 'glm' not found",
+		},
+		{
+			name: "unknown parent",
+			providers: map[string]map[string]interface{}{
+				"glm-air": {"extends": "glm"},
+			},
+			resolve: "glm-air",
+			wantErr: "provider 'glm-air' extends unknown provider 'glm'",
+		},
+		{
+			name: "self cycle",
+			providers: map[string]map[string]interface{}{
+				"a": {"extends": "a"},
+			},
+			resolve: "a",
+			wantErr: "extends cycle a -> a",
+		},
+		{
+			name: "longer cycle",
+			providers: map[string]map[string]interface{}{
+				"a": {"extends": "b"},
+				"b": {"extends": "c"},
+				"c": {"extends": "a"},
+			},
+			resolve: "a",
+			wantErr: "extends cycle a -> b -> c -> a",
+		},
+		{
+			name: "extends is not a string",
+			providers: map[string]map[string]interface{}{
+				"a": {"extends": []interface{}{"b"}},
+			},
+			resolve: "a",
+			wantErr: "extends must be a provider name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ResolveProvider(&Config{Providers: tt.providers}, tt.resolve)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveProvider() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvidersExtending(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]map[string]interface{}{
+			"glm":     {},
+			"glm-b":   {"extends": "glm"},
+			"glm-a":   {"extends": "glm"},
+			"glm-a-2": {"extends": "glm-a"},
+		},
+	}
+	got := ProvidersExtending(cfg, "glm")
+	if !reflect.DeepEqual(got, []string{"glm-a", "glm-b"}) {
+		t.Errorf("ProvidersExtending() = %v, want [glm-a glm-b]", got)
+	}
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/jsonc"
+)
+
+// FragmentDirName is the directory next to ccc.json holding config fragments.
+const FragmentDirName = "ccc.d"
+
+// Fragment is a file of the ccc.d directory. Fragments are merged in lexical
+// order of their file names, each overriding the ones before it, and ccc.json
+// is merged on top of all of them.
+type Fragment struct {
+	Path string
+	Data map[string]interface{}
+}
+
+// GetFragmentDir returns the path of the ccc.d directory.
+func GetFragmentDir() string {
+	return filepath.Join(GetDir(), FragmentDirName)
+}
+
+// loadFragments reads the *.json files of the ccc.d directory in lexical
+// order. A missing directory means no fragments.
+func loadFragments() ([]*Fragment, error) {
+	paths, err := filepath.Glob(filepath.Join(GetFragmentDir(), "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config fragments: %w", err)
+	}
+	sort.Strings(paths)
+
+	var fragments []*Fragment
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config fragment: %w", err)
+		}
+		data, err := parseConfigFile(path, raw)
+		if err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, &Fragment{Path: path, Data: data})
+	}
+	return fragments, nil
+}
+
+// parseConfigFile checks that raw is a valid ccc.json (JSONC, known keys,
+// value types) and returns it as a generic tree. Errors include the position
+// in the file.
+func parseConfigFile(path string, raw []byte) (map[string]interface{}, error) {
+	data := jsonc.Strip(raw)
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", locateParseError(path, data, err))
+	}
+	if err := checkUnknownKeys(path, data); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := checkMergeStrategies(path, data, cfg.Merge); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	var tree map[string]interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	return tree, nil
+}
+
+// mergeFragments deep-merges the fragments in order.
+func mergeFragments(fragments []*Fragment) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for _, f := range fragments {
+		merged = DeepMerge(merged, f.Data)
+	}
+	return merged
+}
+
+// SourceFiles returns the files the configuration is merged from, in the
+// order they are applied: the fragments of ccc.d, ccc.json and the secrets
+// file. Files that don't exist are left out.
+func SourceFiles(cfg *Config) ([]*Fragment, error) {
+	files := append([]*Fragment{}, cfg.Fragments...)
+	main, err := readMainTree()
+	if err != nil {
+		return nil, err
+	}
+	if main != nil {
+		files = append(files, &Fragment{Path: GetConfigPath(), Data: main})
+	}
+	if cfg.Secrets != nil {
+		files = append(files, &Fragment{Path: GetSecretsPath(), Data: cfg.Secrets})
+	}
+	return files, nil
+}
+
+// fragmentBase returns the merged fragments of cfg, normalized like ToMap so
+// empty values compare equal to those of the configuration.
+func fragmentBase(cfg *Config) (map[string]interface{}, error) {
+	if len(cfg.Fragments) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	fragmentCfg, err := FromMap(mergeFragments(cfg.Fragments))
+	if err != nil {
+		return nil, err
+	}
+	return ToMap(fragmentCfg)
+}
+
+// diffLayer returns what ccc.json must contain so that, merged on top of base
+// (the fragments), it gives full. Values that come from the fragments
+// unchanged are left out, unless ccc.json (given as previous) already repeats
+// them. Values defined by a fragment cannot be removed from ccc.json; that is
+// an error naming the fragment to edit instead.
+func diffLayer(full, base, previous map[string]interface{}, path []string, fragments []*Fragment) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for key, value := range full {
+		baseValue, inBase := base[key]
+		prevValue, inPrevious := previous[key]
+		valueMap, isMap := value.(map[string]interface{})
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+
+		switch {
+		case isMap && baseIsMap:
+			prevMap, _ := prevValue.(map[string]interface{})
+			sub, err := diffLayer(valueMap, baseMap, prevMap, append(path, key), fragments)
+			if err != nil {
+				return nil, err
+			}
+			if len(sub) > 0 || inPrevious {
+				result[key] = sub
+			}
+		case inBase && reflect.DeepEqual(value, baseValue):
+			if inPrevious && reflect.DeepEqual(value, prevValue) {
+				result[key] = prevValue
+			}
+		case value == nil && !inBase && !inPrevious:
+			// Unset in every layer
+		default:
+			result[key] = value
+		}
+	}
+
+	for key, baseValue := range base {
+		if _, exists := full[key]; !exists && baseValue != nil {
+			keyPath := append(append([]string{}, path...), key)
+			return nil, fmt.Errorf("cannot remove '%s': it is defined in %s; edit that file instead",
+				strings.Join(keyPath, "."), definingFragment(fragments, keyPath))
+		}
+	}
+	return result, nil
+}
+
+// definingFragment returns the path of the last fragment that sets path.
+func definingFragment(fragments []*Fragment, path []string) string {
+	for i := len(fragments) - 1; i >= 0; i-- {
+		if _, exists := GetPath(fragments[i].Data, path); exists {
+			return fragments[i].Path
+		}
+	}
+	return GetFragmentDir()
+}
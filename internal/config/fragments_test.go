@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFragment writes a file to ccc.d.
+func writeFragment(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(GetFragmentDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(GetFragmentDir(), name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadFragments(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeFragment(t, "20-override.json", `{"providers": {"team": {"env": {"ANTHROPIC_MODEL": "m2"}}}}`)
+	writeFragment(t, "10-team.json", `{
+  // Shared team gateway
+  "settings": {"theme": "dark"},
+  "providers": {"team": {"env": {"ANTHROPIC_BASE_URL": "https://gw", "ANTHROPIC_MODEL": "m1"}}},
+}`)
+	writeFragment(t, "notes.txt", `not a fragment`)
+
+	// ccc.json may be missing when there are fragments
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := GetModel(cfg.Providers["team"]); got != "m2" {
+		t.Errorf("team model = %q, want m2 (later fragment wins)", got)
+	}
+	if len(cfg.Fragments) != 2 || !strings.HasSuffix(cfg.Fragments[0].Path, "10-team.json") {
+		t.Errorf("Fragments = %v, want 10-team.json then 20-override.json", cfg.Fragments)
+	}
+
+	// ccc.json is applied last
+	if err := os.WriteFile(GetConfigPath(), []byte(`{"providers": {"team": {"env": {"ANTHROPIC_MODEL": "m3"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := GetModel(cfg.Providers["team"]); got != "m3" {
+		t.Errorf("team model = %q, want m3 (ccc.json wins)", got)
+	}
+	if got := GetBaseURL(cfg.Providers["team"]); got != "https://gw" {
+		t.Errorf("team base URL = %q, want https://gw from the fragment", got)
+	}
+	if cfg.Settings["theme"] != "dark" {
+		t.Errorf("settings.theme = %v, want dark from the fragment", cfg.Settings["theme"])
+	}
+}
+
+func TestLoadFragmentErrors(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeFragment(t, "team.json", "{\n  \"provider\": {}\n}")
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "team.json:2:3: unknown key \"provider\"") {
+		t.Errorf("Load() error = %v, want the fragment position", err)
+	}
+}
+
+func TestSaveWithFragments(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeFragment(t, "team.json", `{"providers": {"team": {"env": {"ANTHROPIC_BASE_URL": "https://gw"}}}}`)
+	main := `{
+  "providers": {
+    "team": {"env": {"ANTHROPIC_BASE_URL": "https://gw"}},
+    "mine": {}
+  }
+}`
+	if err := os.WriteFile(GetConfigPath(), []byte(main), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only current_provider is added; the fragment is not flattened into ccc.json
+	cfg.CurrentProvider = "team"
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	data, err := os.ReadFile(GetConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(main, "\n}", ",\n  \"current_provider\": \"team\"\n}", 1)
+	if string(data) != want {
+		t.Errorf("ccc.json =\n%s\nwant:\n%s", data, want)
+	}
+
+	// Changing a fragment value writes just the override
+	cfg.Providers["team"]["env"].(map[string]interface{})["ANTHROPIC_MODEL"] = "m1"
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetModel(reloaded.Providers["team"]) != "m1" || GetBaseURL(reloaded.Providers["team"]) != "https://gw" {
+		t.Errorf("reloaded team = %v, want model m1 and the fragment base URL", reloaded.Providers["team"])
+	}
+
+	// Values defined by a fragment cannot be removed through ccc.json
+	delete(cfg.Providers, "team")
+	err = Save(cfg)
+	if err == nil || !strings.Contains(err.Error(), "cannot remove 'providers.team': it is defined in "+filepath.Join(GetFragmentDir(), "team.json")) {
+		t.Errorf("Save() error = %v, want the defining fragment", err)
+	}
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/guyskk/ccc/internal/jsonc"
+)
+
+// HistoryLimit is the number of snapshots kept; older ones are pruned.
+// This variable allows tests to use a smaller ring.
+var HistoryLimit = 20
+
+// Snapshot is a saved copy of the files ccc writes, taken before ccc
+// changed them.
+type Snapshot struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	// Files maps each tracked file name to whether it existed at the time.
+	Files map[string]bool `json:"files"`
+	// Undo marks a snapshot of the files an undo replaced. Undo skips these
+	// snapshots, so repeated undos keep walking back.
+	Undo bool `json:"undo,omitempty"`
+}
+
+// GetHistoryDir returns the directory holding the snapshots.
+func GetHistoryDir() string {
+	return filepath.Join(GetStateDir(), "history")
+}
+
+// historyFiles returns the files a snapshot covers, by name.
+// The managed record is included so it always matches settings.json.
+func historyFiles() map[string]string {
+	return map[string]string{
+		"settings.json": GetSettingsPath(),
+		"ccc.json":      GetConfigPath(),
+		SecretsFileName: GetSecretsPath(),
+		"managed.json":  GetManagedPath(),
+	}
+}
+
+// TakeSnapshot saves the current content of settings.json, ccc.json, the
+// secrets file and the managed record. reason describes the change that is
+// about to happen; files that don't exist yet are recorded as missing, so
+// restoring the snapshot removes them. No snapshot is taken (and nil is
+// returned) if the files are identical to the latest snapshot. Snapshots
+// beyond HistoryLimit are pruned, oldest first.
+//
+// Changes made by ccc use WithSnapshot instead, which only keeps the
+// snapshot if the change modified the files.
+func TakeSnapshot(reason string) (*Snapshot, error) {
+	current, err := readHistoryFiles()
+	if err != nil {
+		return nil, err
+	}
+	return saveSnapshot(reason, false, current)
+}
+
+// WithSnapshot runs change, which modifies the files covered by snapshots,
+// and keeps a snapshot of the files from before it described by reason, as
+// TakeSnapshot does. The snapshot is only saved if the files differ once
+// change returns: a no-op change, or one that failed and restored every file,
+// leaves no snapshot behind. A change that failed midway is still recorded,
+// so it can be undone. Returns the error of change, if any.
+func WithSnapshot(reason string, change func() error) error {
+	_, err := withSnapshot(reason, false, change)
+	return err
+}
+
+// Undo restores snapshot and removes it from the history. The files it
+// replaces are kept in a snapshot marked Undo, which is returned (nil if the
+// files didn't change), so edits made since snapshot was taken can still be
+// restored.
+func Undo(snapshot *Snapshot) (*Snapshot, error) {
+	return withSnapshot(fmt.Sprintf("undo %s", snapshot.Reason), true, func() error {
+		if err := RestoreSnapshot(snapshot); err != nil {
+			return err
+		}
+		return DeleteSnapshot(snapshot)
+	})
+}
+
+// withSnapshot implements WithSnapshot and Undo, returning the snapshot saved.
+func withSnapshot(reason string, undo bool, change func() error) (*Snapshot, error) {
+	before, err := readHistoryFiles()
+	if err != nil {
+		return nil, err
+	}
+	changeErr := change()
+	var saved *Snapshot
+	after, err := readHistoryFiles()
+	if err == nil && !sameFiles(before, after) {
+		saved, err = saveSnapshot(reason, undo, before)
+	}
+	if changeErr != nil {
+		return saved, changeErr
+	}
+	return saved, err
+}
+
+// readHistoryFiles returns the content of the files snapshots cover, by
+// name. Files that don't exist are left out.
+func readHistoryFiles() (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for name, path := range historyFiles() {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s for snapshot: %w", name, err)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// sameFiles reports whether a and b hold the same files with the same content.
+func sameFiles(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, data := range a {
+		other, exists := b[name]
+		if !exists || !bytes.Equal(data, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// saveSnapshot saves files (as returned by readHistoryFiles) as a new
+// snapshot, unless they are identical to the latest one.
+func saveSnapshot(reason string, undo bool, current map[string][]byte) (*Snapshot, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 && snapshotEquals(snapshots[len(snapshots)-1], current) {
+		return nil, nil
+	}
+
+	now := time.Now()
+	snapshot := &Snapshot{
+		ID:     newSnapshotID(now, snapshots),
+		Time:   now,
+		Reason: reason,
+		Files:  make(map[string]bool),
+		Undo:   undo,
+	}
+	dir := filepath.Join(GetHistoryDir(), snapshot.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	for name := range historyFiles() {
+		data, exists := current[name]
+		snapshot.Files[name] = exists
+		if !exists {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+	meta, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "snapshot.json"), meta, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	// Prune the oldest snapshots beyond the limit
+	snapshots = append(snapshots, snapshot)
+	for len(snapshots) > HistoryLimit {
+		os.RemoveAll(filepath.Join(GetHistoryDir(), snapshots[0].ID))
+		snapshots = snapshots[1:]
+	}
+	return snapshot, nil
+}
+
+// newSnapshotID returns a sortable timestamp ID that is unique among existing.
+func newSnapshotID(now time.Time, existing []*Snapshot) string {
+	base := now.Format("20060102-150405")
+	taken := make(map[string]bool, len(existing))
+	for _, s := range existing {
+		taken[s.ID] = true
+	}
+	id := base
+	for n := 2; taken[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	return id
+}
+
+// snapshotEquals reports whether snapshot holds exactly the given files.
+func snapshotEquals(snapshot *Snapshot, files map[string][]byte) bool {
+	for name := range historyFiles() {
+		data, exists, err := ReadSnapshotFile(snapshot, name)
+		if err != nil {
+			return false
+		}
+		current, currentExists := files[name]
+		if exists != currentExists || !bytes.Equal(data, current) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListSnapshots returns all snapshots, oldest first.
+func ListSnapshots() ([]*Snapshot, error) {
+	entries, err := os.ReadDir(GetHistoryDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(GetHistoryDir(), entry.Name(), "snapshot.json"))
+		if err != nil {
+			// Incomplete snapshot (e.g. interrupted while writing); ignore it
+			continue
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.ID != entry.Name() {
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// FindSnapshot returns the snapshot with the given ID.
+func FindSnapshot(id string) (*Snapshot, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot '%s' not found (see `ccc history config`)", id)
+}
+
+// ReadSnapshotFile returns the content of a file saved in snapshot and
+// whether the file existed when the snapshot was taken.
+func ReadSnapshotFile(snapshot *Snapshot, name string) ([]byte, bool, error) {
+	if !snapshot.Files[name] {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(filepath.Join(GetHistoryDir(), snapshot.ID, name))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read snapshot %s: %w", snapshot.ID, err)
+	}
+	return data, true, nil
+}
+
+// ReadCurrentFile returns the current content of a file covered by snapshots
+// and whether it exists.
+func ReadCurrentFile(name string) ([]byte, bool, error) {
+	path, ok := historyFiles()[name]
+	if !ok {
+		return nil, false, fmt.Errorf("'%s' is not kept in snapshots", name)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, true, nil
+}
+
+// RestoreSnapshot puts the files saved in snapshot back in place. Files that
+// did not exist when the snapshot was taken are removed.
+func RestoreSnapshot(snapshot *Snapshot) error {
+	for name, path := range historyFiles() {
+		data, exists, err := ReadSnapshotFile(snapshot, name)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		if err := WriteFileAtomic(path, data, 0600); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DeleteSnapshot removes a snapshot from the history.
+func DeleteSnapshot(snapshot *Snapshot) error {
+	if err := os.RemoveAll(filepath.Join(GetHistoryDir(), snapshot.ID)); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", snapshot.ID, err)
+	}
+	return nil
+}
+
+// ReplaceInSnapshots rewrites the copies of ccc.json and the secrets file kept
+// in snapshots: every string value equal to a key of replace becomes the
+// mapped value, keeping formatting and comments. It is used to encrypt
+// secrets that were saved in plain text, so restoring a snapshot can't bring
+// them back. Returns the IDs of the snapshots changed.
+func ReplaceInSnapshots(replace map[string]string) ([]string, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, snapshot := range snapshots {
+		snapshotChanged := false
+		for _, name := range []string{"ccc.json", SecretsFileName} {
+			data, exists, err := ReadSnapshotFile(snapshot, name)
+			if err != nil {
+				return changed, err
+			}
+			if !exists {
+				continue
+			}
+			var tree interface{}
+			if err := json.Unmarshal(jsonc.Strip(data), &tree); err != nil {
+				// A snapshot of a broken file; leave it as it was
+				continue
+			}
+			if !replaceStrings(tree, replace) {
+				continue
+			}
+			updated, err := json.Marshal(tree)
+			if err != nil {
+				return changed, fmt.Errorf("failed to marshal snapshot %s: %w", snapshot.ID, err)
+			}
+			patched, err := jsonc.Patch(data, updated)
+			if err != nil {
+				return changed, fmt.Errorf("failed to rewrite snapshot %s: %w", snapshot.ID, err)
+			}
+			if err := WriteFileAtomic(filepath.Join(GetHistoryDir(), snapshot.ID, name), patched, 0600); err != nil {
+				return changed, fmt.Errorf("failed to rewrite snapshot %s: %w", snapshot.ID, err)
+			}
+			snapshotChanged = true
+		}
+		if snapshotChanged {
+			changed = append(changed, snapshot.ID)
+		}
+	}
+	return changed, nil
+}
+
+// replaceStrings replaces in place the string values of tree found in
+// replace, and reports whether any was replaced.
+func replaceStrings(tree interface{}, replace map[string]string) bool {
+	replaced := false
+	switch v := tree.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if s, ok := child.(string); ok {
+				if r, found := replace[s]; found {
+					v[key] = r
+					replaced = true
+				}
+			} else if replaceStrings(child, replace) {
+				replaced = true
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if s, ok := child.(string); ok {
+				if r, found := replace[s]; found {
+					v[i] = r
+					replaced = true
+				}
+			} else if replaceStrings(child, replace) {
+				replaced = true
+			}
+		}
+	}
+	return replaced
+}
+
+// SnapshotsContaining returns the IDs of the snapshots holding a file that
+// contains any of values verbatim.
+func SnapshotsContaining(values []string) ([]string, error) {
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	var found []string
+	for _, snapshot := range snapshots {
+		if snapshotContains(snapshot, values) {
+			found = append(found, snapshot.ID)
+		}
+	}
+	return found, nil
+}
+
+// snapshotContains reports whether a file of snapshot contains any of values.
+func snapshotContains(snapshot *Snapshot, values []string) bool {
+	for name := range historyFiles() {
+		data, exists, err := ReadSnapshotFile(snapshot, name)
+		if err != nil || !exists {
+			continue
+		}
+		for _, value := range values {
+			if value != "" && bytes.Contains(data, []byte(value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
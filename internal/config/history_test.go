@@ -0,0 +1,219 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	// Nothing exists yet: the snapshot records the files as missing
+	first, err := TakeSnapshot("first switch")
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	if first == nil || first.Files["ccc.json"] || first.Files["settings.json"] {
+		t.Fatalf("TakeSnapshot() = %+v, want snapshot of missing files", first)
+	}
+
+	if err := SaveSettings(map[string]interface{}{"model": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := TakeSnapshot("switch to glm")
+	if err != nil {
+		t.Fatalf("TakeSnapshot() error = %v", err)
+	}
+	if second == nil || !second.Files["settings.json"] || second.Reason != "switch to glm" {
+		t.Fatalf("TakeSnapshot() = %+v, want snapshot with settings.json", second)
+	}
+	if second.ID == first.ID {
+		t.Error("snapshot IDs must be unique")
+	}
+
+	// Unchanged files are not snapshotted again
+	if dup, err := TakeSnapshot("again"); err != nil || dup != nil {
+		t.Errorf("TakeSnapshot() of unchanged files = %+v, %v, want nil", dup, err)
+	}
+
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != first.ID || snapshots[1].ID != second.ID {
+		t.Errorf("ListSnapshots() = %v, want [first second]", snapshots)
+	}
+	data, exists, err := ReadSnapshotFile(snapshots[1], "settings.json")
+	if err != nil || !exists || string(data) != "{\n  \"model\": \"a\"\n}" {
+		t.Errorf("ReadSnapshotFile() = %q, %v, %v", data, exists, err)
+	}
+}
+
+func TestTakeSnapshotPrunes(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	originalLimit := HistoryLimit
+	HistoryLimit = 3
+	defer func() { HistoryLimit = originalLimit }()
+
+	var ids []string
+	for _, model := range []string{"a", "b", "c", "d", "e"} {
+		if err := SaveSettings(map[string]interface{}{"model": model}); err != nil {
+			t.Fatal(err)
+		}
+		s, err := TakeSnapshot("set " + model)
+		if err != nil {
+			t.Fatalf("TakeSnapshot() error = %v", err)
+		}
+		ids = append(ids, s.ID)
+	}
+
+	snapshots, err := ListSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("len(ListSnapshots()) = %d, want 3", len(snapshots))
+	}
+	for i, s := range snapshots {
+		if s.ID != ids[i+2] {
+			t.Errorf("snapshot %d = %s, want %s (oldest pruned first)", i, s.ID, ids[i+2])
+		}
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := SaveSettings(map[string]interface{}{"model": "before"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := TakeSnapshot("switch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change settings.json and create ccc.json after the snapshot
+	if err := SaveSettings(map[string]interface{}{"model": "after"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(&Config{CurrentProvider: "glm"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RestoreSnapshot(snapshot); err != nil {
+		t.Fatalf("RestoreSnapshot() error = %v", err)
+	}
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["model"] != "before" {
+		t.Errorf("model = %v, want before", settings["model"])
+	}
+	if _, err := os.Stat(GetConfigPath()); !os.IsNotExist(err) {
+		t.Error("ccc.json did not exist in the snapshot and should be removed")
+	}
+
+	if err := DeleteSnapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindSnapshot(snapshot.ID); err == nil {
+		t.Error("FindSnapshot() should fail for a deleted snapshot")
+	}
+}
+
+func TestReplaceInSnapshots(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	ccc := "{\n  // glm token\n  \"providers\": {\"glm\": {\"env\": {\"ANTHROPIC_AUTH_TOKEN\": \"sk-plain\"}}}\n}"
+	if err := os.WriteFile(GetConfigPath(), []byte(ccc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSettings(map[string]interface{}{"env": map[string]interface{}{"TOKEN": "sk-plain"}}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := TakeSnapshot("switch to glm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ReplaceInSnapshots(map[string]string{"sk-plain": "enc:v1:x"})
+	if err != nil || len(changed) != 1 || changed[0] != snapshot.ID {
+		t.Fatalf("ReplaceInSnapshots() = %v, %v", changed, err)
+	}
+	data, _, err := ReadSnapshotFile(snapshot, "ccc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  // glm token\n  \"providers\": {\"glm\": {\"env\": {\"ANTHROPIC_AUTH_TOKEN\": \"enc:v1:x\"}}}\n}"
+	if string(data) != want {
+		t.Errorf("snapshot ccc.json = %q, want %q", data, want)
+	}
+
+	// settings.json is left alone, and still reported
+	found, err := SnapshotsContaining([]string{"sk-plain"})
+	if err != nil || len(found) != 1 || found[0] != snapshot.ID {
+		t.Errorf("SnapshotsContaining() = %v, %v, want the snapshot", found, err)
+	}
+	if found, _ := SnapshotsContaining([]string{"sk-other"}); len(found) != 0 {
+		t.Errorf("SnapshotsContaining(sk-other) = %v, want none", found)
+	}
+}
+
+func TestWithSnapshot(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := SaveSettings(map[string]interface{}{"model": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	count := func() int {
+		snapshots, err := ListSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(snapshots)
+	}
+
+	// A change that writes the same content leaves no snapshot
+	if err := WithSnapshot("noop", func() error {
+		return SaveSettings(map[string]interface{}{"model": "a"})
+	}); err != nil || count() != 0 {
+		t.Fatalf("WithSnapshot(noop) = %v, %d snapshots, want none", err, count())
+	}
+
+	// A failed change that restored the files leaves no snapshot either
+	failure := errors.New("failed")
+	if err := WithSnapshot("rolled back", func() error {
+		if err := SaveSettings(map[string]interface{}{"model": "b"}); err != nil {
+			return err
+		}
+		if err := SaveSettings(map[string]interface{}{"model": "a"}); err != nil {
+			return err
+		}
+		return failure
+	}); !errors.Is(err, failure) || count() != 0 {
+		t.Fatalf("WithSnapshot(rolled back) = %v, %d snapshots, want the failure and none", err, count())
+	}
+
+	// A change keeps the files from before it
+	if err := WithSnapshot("set model b", func() error {
+		return SaveSettings(map[string]interface{}{"model": "b"})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := ListSnapshots()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Reason != "set model b" {
+		t.Fatalf("ListSnapshots() = %v, %v, want the snapshot of set model b", snapshots, err)
+	}
+	data, _, err := ReadSnapshotFile(snapshots[0], "settings.json")
+	if err != nil || string(data) != "{\n  \"model\": \"a\"\n}" {
+		t.Errorf("snapshot settings.json = %q, %v, want the content before the change", data, err)
+	}
+}
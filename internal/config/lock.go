@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// LockTimeout is how long Lock waits for another ccc process to release the
+// lock. This variable allows tests to shorten the wait.
+var LockTimeout = 10 * time.Second
+
+// lockRetryInterval is how often Lock retries while the lock is held.
+const lockRetryInterval = 50 * time.Millisecond
+
+// GetLockPath returns the path to the lock file guarding ccc's config writes.
+func GetLockPath() string {
+	return filepath.Join(GetStateDir(), "ccc.lock")
+}
+
+// Lock takes the advisory lock that serializes read-modify-write cycles on
+// ccc.json and settings.json between ccc processes. It waits up to LockTimeout
+// and returns a function that releases the lock.
+//
+// The lock is not reentrant: a process must not call Lock again before
+// releasing it.
+func Lock() (func(), error) {
+	lockPath := GetLockPath()
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) && !errors.Is(err, syscall.EINTR) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", lockPath, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("another ccc process is updating the configuration: "+
+				"could not lock %s within %s; retry once it has finished", lockPath, LockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
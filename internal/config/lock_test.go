@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	originalTimeout := LockTimeout
+	LockTimeout = 200 * time.Millisecond
+	defer func() { LockTimeout = originalTimeout }()
+
+	unlock, err := Lock()
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	// A second lock (as from another ccc process) times out with a clear error
+	start := time.Now()
+	_, err = Lock()
+	if err == nil {
+		t.Fatal("Lock() should fail while the lock is held")
+	}
+	if !strings.Contains(err.Error(), "another ccc process") || !strings.Contains(err.Error(), GetLockPath()) {
+		t.Errorf("error should explain the lock is held, got: %v", err)
+	}
+	if time.Since(start) < LockTimeout {
+		t.Error("Lock() should wait for LockTimeout before failing")
+	}
+
+	// Waiting succeeds once the holder releases the lock
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlock()
+	}()
+	unlock2, err := Lock()
+	if err != nil {
+		t.Fatalf("Lock() after release error = %v", err)
+	}
+	unlock2()
+}
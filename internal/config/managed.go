@@ -0,0 +1,252 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ManagedEntry records a single value that ccc wrote into settings.json.
+// Path is the key path from the settings root (a slice, so keys containing
+// dots are unambiguous) and Value is exactly what was written at that path.
+// When ccc merged its elements into an array of the user (see DeepMergeWith),
+// Items holds the elements ccc added instead.
+type ManagedEntry struct {
+	Path  []string      `json:"path"`
+	Value interface{}   `json:"value"`
+	Items []interface{} `json:"items,omitempty"`
+}
+
+// ManagedRecord is ccc's bookkeeping of what it wrote into settings.json
+// during the last provider switch. On the next switch the recorded values are
+// removed from settings.json before merging, so a previous provider's settings
+// never leak into the "user" layer and override the new provider.
+type ManagedRecord struct {
+	// Provider is the provider that was active when the record was written.
+	Provider string `json:"provider"`
+	// Entries lists every leaf value ccc contributed to settings.json.
+	Entries []ManagedEntry `json:"entries"`
+}
+
+// GetManagedPath returns the path to the managed-keys record.
+func GetManagedPath() string {
+	return filepath.Join(GetStateDir(), "managed.json")
+}
+
+// LoadManaged reads the managed-keys record.
+// Returns nil if the record doesn't exist (not an error).
+func LoadManaged() (*ManagedRecord, error) {
+	data, err := os.ReadFile(GetManagedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read managed record: %w", err)
+	}
+
+	var record ManagedRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to parse managed record: %w", err)
+	}
+	return &record, nil
+}
+
+// SaveManaged writes the managed-keys record.
+func SaveManaged(record *ManagedRecord) error {
+	stateDir := GetStateDir()
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal managed record: %w", err)
+	}
+
+	if err := WriteFileAtomic(GetManagedPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to write managed record: %w", err)
+	}
+	return nil
+}
+
+// StripManaged removes the values recorded in record from settings.
+// A value is only removed when it still equals what ccc wrote; if the user
+// edited it since, the edit is kept as a user setting. Array elements ccc
+// added are removed from the array, leaving the user's own. Maps left empty
+// by a removal are pruned. Returns a new map without modifying the input.
+func StripManaged(settings map[string]interface{}, record *ManagedRecord) map[string]interface{} {
+	result := deepCopy(settings)
+	if result == nil || record == nil {
+		return result
+	}
+
+	for _, entry := range record.Entries {
+		stripPath(result, entry.Path, entry)
+	}
+	return result
+}
+
+// stripPath deletes path from m if the value there equals entry.Value (or
+// removes entry.Items from the array there), then prunes maps along the path
+// that became empty. Reports whether m itself is now empty because of the
+// removal.
+func stripPath(m map[string]interface{}, path []string, entry ManagedEntry) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	key := path[0]
+	val, exists := m[key]
+	if !exists {
+		return false
+	}
+
+	if len(path) == 1 {
+		if entry.Items != nil {
+			if arr, ok := val.([]interface{}); ok {
+				m[key] = removeItems(arr, entry.Items)
+			}
+			return false
+		}
+		if !reflect.DeepEqual(val, entry.Value) {
+			return false
+		}
+		delete(m, key)
+		return len(m) == 0
+	}
+
+	child, ok := val.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if stripPath(child, path[1:], entry) {
+		delete(m, key)
+	}
+	return len(m) == 0
+}
+
+// removeItems returns arr without one occurrence of each of items.
+func removeItems(arr, items []interface{}) []interface{} {
+	result := deepCopySlice(arr)
+	for _, item := range items {
+		for i, v := range result {
+			if reflect.DeepEqual(v, item) {
+				result = append(result[:i], result[i+1:]...)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// CollectManaged returns an entry for every leaf of written that is not
+// overridden by userSettings, i.e. every value ccc itself puts into
+// settings.json. For an array merged into the user's with one of strategies,
+// the entry lists the elements ccc added. Entries are sorted by path for a
+// stable record.
+func CollectManaged(written, userSettings map[string]interface{}, strategies map[string]string) []ManagedEntry {
+	var entries []ManagedEntry
+	collectManaged(written, userSettings, strategies, nil, &entries)
+
+	sort.Slice(entries, func(i, j int) bool {
+		return joinPath(entries[i].Path) < joinPath(entries[j].Path)
+	})
+	return entries
+}
+
+// BootstrapManaged builds the record of a settings.json that ccc wrote before
+// it kept one, for provider and the settings it contributes (written, without
+// env). As the user's own values are unknown, every leaf of written is
+// recorded; StripManaged only removes those still equal to what is in
+// settings.json. For an array merged with one of strategies, all elements of
+// written are recorded as added by ccc.
+func BootstrapManaged(provider string, written map[string]interface{}, strategies map[string]string) *ManagedRecord {
+	entries := CollectManaged(written, nil, strategies)
+	for i, entry := range entries {
+		arr, ok := entry.Value.([]interface{})
+		if !ok {
+			continue
+		}
+		switch strategies[joinPath(entry.Path)] {
+		case MergeAppend, MergePrepend, MergeUnion:
+			entries[i] = ManagedEntry{Path: entry.Path, Items: arr}
+		}
+	}
+	return &ManagedRecord{Provider: provider, Entries: entries}
+}
+
+func collectManaged(written, user map[string]interface{}, strategies map[string]string, prefix []string, entries *[]ManagedEntry) {
+	for key, value := range written {
+		path := append(append([]string{}, prefix...), key)
+
+		userVal, userHas := user[key]
+		if !userHas {
+			collectLeaves(value, path, entries)
+			continue
+		}
+
+		// Both sides are arrays: ccc's elements are merged with the user's
+		if items := addedItems(strategies[joinPath(path)], value, userVal); len(items) > 0 {
+			*entries = append(*entries, ManagedEntry{Path: path, Items: items})
+			continue
+		}
+
+		// Both sides are maps: the user only overrides some of the nested keys
+		writtenMap, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		userMap, ok := userVal.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		collectManaged(writtenMap, userMap, strategies, path, entries)
+	}
+}
+
+// addedItems returns the elements of the written array that strategy adds to
+// the user's array, or nil if the arrays are not merged.
+func addedItems(strategy string, written, user interface{}) []interface{} {
+	writtenArr, ok := written.([]interface{})
+	if !ok {
+		return nil
+	}
+	userArr, ok := user.([]interface{})
+	if !ok {
+		return nil
+	}
+	switch strategy {
+	case MergeAppend, MergePrepend:
+		return deepCopySlice(writtenArr)
+	case MergeUnion:
+		var items []interface{}
+		for _, item := range writtenArr {
+			if !containsValue(userArr, item) {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+// collectLeaves records every leaf under value. Empty maps count as leaves so
+// that ccc can remove containers it created.
+func collectLeaves(value interface{}, path []string, entries *[]ManagedEntry) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for key, child := range m {
+			collectLeaves(child, append(append([]string{}, path...), key), entries)
+		}
+		return
+	}
+	*entries = append(*entries, ManagedEntry{Path: path, Value: value})
+}
+
+// joinPath renders a key path for sorting and display.
+func joinPath(path []string) string {
+	return strings.Join(path, ".")
+}
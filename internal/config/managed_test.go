@@ -0,0 +1,181 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectManaged(t *testing.T) {
+	written := map[string]interface{}{
+		"model": "glm-4.7",
+		"permissions": map[string]interface{}{
+			"defaultMode": "acceptEdits",
+			"allow":       []interface{}{"Bash"},
+		},
+		"statusLine": map[string]interface{}{},
+	}
+	user := map[string]interface{}{
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Read"},
+		},
+	}
+
+	got := CollectManaged(written, user, nil)
+	want := []ManagedEntry{
+		{Path: []string{"model"}, Value: "glm-4.7"},
+		{Path: []string{"permissions", "defaultMode"}, Value: "acceptEdits"},
+		{Path: []string{"statusLine"}, Value: map[string]interface{}{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectManaged() = %v, want %v", got, want)
+	}
+}
+
+func TestCollectManagedArrays(t *testing.T) {
+	written := map[string]interface{}{
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Bash", "Read"},
+			"deny":  []interface{}{"WebFetch"},
+		},
+	}
+	user := map[string]interface{}{
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Read"},
+			"deny":  []interface{}{"Edit"},
+		},
+	}
+
+	// Only the elements ccc adds are recorded; replaced arrays are the user's
+	got := CollectManaged(written, user, map[string]string{"permissions.allow": MergeUnion})
+	want := []ManagedEntry{
+		{Path: []string{"permissions", "allow"}, Items: []interface{}{"Bash"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CollectManaged() = %v, want %v", got, want)
+	}
+
+	// Stripping them leaves the user's own array
+	settings := map[string]interface{}{
+		"permissions": map[string]interface{}{"allow": []interface{}{"Bash", "Read"}},
+	}
+	compareJSON(t, StripManaged(settings, &ManagedRecord{Entries: got}), map[string]interface{}{
+		"permissions": map[string]interface{}{"allow": []interface{}{"Read"}},
+	})
+}
+
+func TestBootstrapManaged(t *testing.T) {
+	written := map[string]interface{}{
+		"model": "glm-4.7",
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Bash"},
+			"deny":  []interface{}{"WebFetch"},
+		},
+	}
+
+	record := BootstrapManaged("glm", written, map[string]string{"permissions.allow": MergeAppend})
+	want := &ManagedRecord{
+		Provider: "glm",
+		Entries: []ManagedEntry{
+			{Path: []string{"model"}, Value: "glm-4.7"},
+			{Path: []string{"permissions", "allow"}, Items: []interface{}{"Bash"}},
+			{Path: []string{"permissions", "deny"}, Value: []interface{}{"WebFetch"}},
+		},
+	}
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("BootstrapManaged() = %v, want %v", record, want)
+	}
+
+	// Values the user changed since are kept
+	settings := map[string]interface{}{
+		"model": "my-own-model",
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Read", "Bash"},
+			"deny":  []interface{}{"WebFetch"},
+		},
+	}
+	compareJSON(t, StripManaged(settings, record), map[string]interface{}{
+		"model":       "my-own-model",
+		"permissions": map[string]interface{}{"allow": []interface{}{"Read"}},
+	})
+}
+
+func TestStripManaged(t *testing.T) {
+	record := &ManagedRecord{
+		Provider: "kimi",
+		Entries: []ManagedEntry{
+			{Path: []string{"model"}, Value: "kimi-k2"},
+			{Path: []string{"permissions", "defaultMode"}, Value: "acceptEdits"},
+			{Path: []string{"missing", "key"}, Value: true},
+		},
+	}
+
+	t.Run("removes unchanged values and prunes empty maps", func(t *testing.T) {
+		settings := map[string]interface{}{
+			"model": "kimi-k2",
+			"permissions": map[string]interface{}{
+				"defaultMode": "acceptEdits",
+			},
+			"enabledPlugins": map[string]interface{}{"p": true},
+		}
+
+		got := StripManaged(settings, record)
+		want := map[string]interface{}{
+			"enabledPlugins": map[string]interface{}{"p": true},
+		}
+		compareJSON(t, got, want)
+
+		if _, exists := settings["model"]; !exists {
+			t.Error("StripManaged() should not modify input")
+		}
+	})
+
+	t.Run("keeps values edited by the user", func(t *testing.T) {
+		settings := map[string]interface{}{
+			"model": "user-model",
+			"permissions": map[string]interface{}{
+				"defaultMode": "acceptEdits",
+				"allow":       []interface{}{"Read"},
+			},
+		}
+
+		got := StripManaged(settings, record)
+		want := map[string]interface{}{
+			"model": "user-model",
+			"permissions": map[string]interface{}{
+				"allow": []interface{}{"Read"},
+			},
+		}
+		compareJSON(t, got, want)
+	})
+
+	t.Run("nil record", func(t *testing.T) {
+		settings := map[string]interface{}{"model": "x"}
+		compareJSON(t, StripManaged(settings, nil), settings)
+	})
+}
+
+func TestManagedRecordRoundTrip(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	record, err := LoadManaged()
+	if err != nil || record != nil {
+		t.Fatalf("LoadManaged() on missing file = %v, %v; want nil, nil", record, err)
+	}
+
+	want := &ManagedRecord{
+		Provider: "glm",
+		Entries:  []ManagedEntry{{Path: []string{"model"}, Value: "glm-4.7"}},
+	}
+	if err := SaveManaged(want); err != nil {
+		t.Fatalf("SaveManaged() error = %v", err)
+	}
+
+	got, err := LoadManaged()
+	if err != nil {
+		t.Fatalf("LoadManaged() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadManaged() = %v, want %v", got, want)
+	}
+}
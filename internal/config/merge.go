@@ -0,0 +1,184 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Array merge strategies, chosen per key path in the merge field of ccc.json.
+// They decide how an array of a higher layer is combined with the array of
+// the layer below it; objects are always merged key by key.
+const (
+	// MergeReplace uses the higher layer's array alone (the default).
+	MergeReplace = "replace"
+	// MergeAppend puts the higher layer's elements after the lower ones.
+	MergeAppend = "append"
+	// MergeUnion appends the higher layer's elements the lower array lacks.
+	MergeUnion = "union"
+	// MergePrepend puts the higher layer's elements before the lower ones.
+	MergePrepend = "prepend"
+)
+
+// mergeStrategies lists the valid strategies, for error messages.
+var mergeStrategies = []string{MergeReplace, MergeAppend, MergeUnion, MergePrepend}
+
+// LiteralKey escapes a value in provider and project settings, where null
+// deletes a key: {"$literal": null} sets the key to null instead, and
+// {"$literal": v} sets any other v as is.
+const LiteralKey = "$literal"
+
+// DeepMergeWith is DeepMerge with array merge strategies: strategies maps the
+// dotted key path of an array (e.g. "permissions.allow") to its strategy.
+// Arrays at other paths are replaced.
+func DeepMergeWith(base, overlay map[string]interface{}, strategies map[string]string) map[string]interface{} {
+	return deepMerge(base, overlay, strategies, "", false)
+}
+
+// MergePatch is DeepMergeWith with the deletions of a JSON merge patch
+// (RFC 7396): a null in overlay removes the key from base. Values wrapped in
+// {"$literal": v} are set to v as is, so a key can still be set to null.
+func MergePatch(base, overlay map[string]interface{}, strategies map[string]string) map[string]interface{} {
+	return deepMerge(base, overlay, strategies, "", true)
+}
+
+func deepMerge(base, overlay map[string]interface{}, strategies map[string]string, prefix string, patch bool) map[string]interface{} {
+	result := deepCopy(base)
+	if result == nil {
+		result = make(map[string]interface{})
+	}
+
+	for key, value := range overlay {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if patch {
+			if value == nil {
+				delete(result, key)
+				continue
+			}
+			if literal, ok := literalValue(value); ok {
+				result[key] = literal
+				continue
+			}
+		}
+		switch existing := result[key].(type) {
+		case map[string]interface{}:
+			// If both are maps, merge them recursively; a literal is a
+			// single value
+			if newMap, ok := value.(map[string]interface{}); ok && !isLiteral(existing) && !isLiteral(newMap) {
+				result[key] = deepMerge(existing, newMap, strategies, path, patch)
+				continue
+			}
+		case []interface{}:
+			if newArr, ok := value.([]interface{}); ok {
+				result[key] = mergeArrays(strategies[path], existing, newArr)
+				continue
+			}
+		}
+		// Otherwise, override with the overlay value
+		if patch {
+			value = patchValue(value)
+		}
+		result[key] = value
+	}
+
+	return result
+}
+
+// literalValue returns v if value is {"$literal": v}.
+func literalValue(value interface{}) (interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, false
+	}
+	literal, ok := m[LiteralKey]
+	return literal, ok
+}
+
+// isLiteral reports whether value is {"$literal": v}.
+func isLiteral(value interface{}) bool {
+	_, ok := literalValue(value)
+	return ok
+}
+
+// patchValue returns a value of a merge patch with nothing under it to merge
+// with: nulls in objects are dropped and literals are unwrapped.
+func patchValue(value interface{}) interface{} {
+	if literal, ok := literalValue(value); ok {
+		return literal
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	result := make(map[string]interface{}, len(m))
+	for key, child := range m {
+		if child != nil {
+			result[key] = patchValue(child)
+		}
+	}
+	return result
+}
+
+// mergeArrays combines the array of a lower layer with the array of the layer
+// above it using strategy.
+func mergeArrays(strategy string, lower, higher []interface{}) []interface{} {
+	switch strategy {
+	case MergeAppend:
+		return append(deepCopySlice(lower), deepCopySlice(higher)...)
+	case MergePrepend:
+		return append(deepCopySlice(higher), deepCopySlice(lower)...)
+	case MergeUnion:
+		result := deepCopySlice(lower)
+		for _, item := range higher {
+			if !containsValue(result, item) {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	return higher
+}
+
+// containsValue reports whether list has an element equal to value.
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMergeStrategies reports every strategy in merge that ccc doesn't know,
+// with its position in data.
+func checkMergeStrategies(path string, data []byte, merge map[string]string) error {
+	keys := make([]string, 0, len(merge))
+	for key := range merge {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		if err := checkMergeStrategy(key, merge[key]); err != nil {
+			errs = append(errs, newPositionError(path, data, valueOffset(data, "merge."+key), err.Error()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkMergeStrategy returns an error if strategy, set for the key path, is
+// not one ccc knows.
+func checkMergeStrategy(path, strategy string) error {
+	for _, known := range mergeStrategies {
+		if strategy == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown merge strategy %q for %q (want %s)", strategy, path, strings.Join(mergeStrategies, ", "))
+}
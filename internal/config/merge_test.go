@@ -0,0 +1,128 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeepMergeWith(t *testing.T) {
+	base := map[string]interface{}{
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Read", "Bash(git:*)"},
+			"deny":  []interface{}{"WebFetch"},
+		},
+	}
+	overlay := map[string]interface{}{
+		"permissions": map[string]interface{}{
+			"allow": []interface{}{"Bash(git:*)", "Edit"},
+			"deny":  []interface{}{"Bash(rm:*)"},
+		},
+	}
+
+	tests := []struct {
+		strategy string
+		want     []interface{}
+	}{
+		{MergeReplace, []interface{}{"Bash(git:*)", "Edit"}},
+		{MergeAppend, []interface{}{"Read", "Bash(git:*)", "Bash(git:*)", "Edit"}},
+		{MergeUnion, []interface{}{"Read", "Bash(git:*)", "Edit"}},
+		{MergePrepend, []interface{}{"Bash(git:*)", "Edit", "Read", "Bash(git:*)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := DeepMergeWith(base, overlay, map[string]string{"permissions.allow": tt.strategy})
+			permissions := got["permissions"].(map[string]interface{})
+			if !reflect.DeepEqual(permissions["allow"], tt.want) {
+				t.Errorf("allow = %v, want %v", permissions["allow"], tt.want)
+			}
+			// Paths without a strategy are replaced
+			if !reflect.DeepEqual(permissions["deny"], []interface{}{"Bash(rm:*)"}) {
+				t.Errorf("deny = %v, want the overlay's", permissions["deny"])
+			}
+		})
+	}
+
+	got := DeepMergeWith(base, overlay, map[string]string{"permissions.allow": MergeAppend})
+	got["permissions"].(map[string]interface{})["allow"].([]interface{})[0] = "changed"
+	if base["permissions"].(map[string]interface{})["allow"].([]interface{})[0] != "Read" {
+		t.Error("DeepMergeWith() must not modify base")
+	}
+}
+
+func TestMergeWithPriorityStrategies(t *testing.T) {
+	base := map[string]interface{}{"permissions": map[string]interface{}{"allow": []interface{}{"Read"}}}
+	provider := map[string]interface{}{"permissions": map[string]interface{}{"allow": []interface{}{"Bash"}}}
+	user := map[string]interface{}{"permissions": map[string]interface{}{"allow": []interface{}{"Edit", "Read"}}}
+
+	got := MergeWithPriority(base, provider, user, map[string]string{"permissions.allow": MergeUnion})
+	want := map[string]interface{}{"permissions": map[string]interface{}{"allow": []interface{}{"Read", "Bash", "Edit"}}}
+	compareJSON(t, got, want)
+}
+
+func TestLoadMergeStrategies(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	config := `{
+  "merge": {
+    "permissions.allow": "union",
+    "permissions.deny": "concat"
+  },
+  "providers": {}
+}`
+	if err := os.WriteFile(GetConfigPath(), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load()
+	want := `ccc.json:4:25: unknown merge strategy "concat" for "permissions.deny" (want replace, append, union, prepend)`
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Load() error = %v, want %q", err, want)
+	}
+
+	if err := os.WriteFile(GetConfigPath(), []byte(strings.Replace(config, "concat", "prepend", 1)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Merge["permissions.deny"] != MergePrepend {
+		t.Errorf("Merge = %v", cfg.Merge)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	base := map[string]interface{}{
+		"alwaysThinkingEnabled": true,
+		"statusLine":            map[string]interface{}{"type": "command", "command": "status.sh"},
+		"permissions":           map[string]interface{}{"defaultMode": "acceptEdits", "allow": []interface{}{"Read"}},
+	}
+	overlay := map[string]interface{}{
+		"alwaysThinkingEnabled": nil,
+		"statusLine":            map[string]interface{}{"$literal": nil},
+		"permissions":           map[string]interface{}{"defaultMode": nil},
+		"model":                 map[string]interface{}{"$literal": map[string]interface{}{"$literal": "x"}},
+		"hooks":                 map[string]interface{}{"Stop": nil, "Notification": []interface{}{}},
+	}
+
+	got := MergePatch(base, overlay, nil)
+	want := map[string]interface{}{
+		"statusLine":  nil,
+		"permissions": map[string]interface{}{"allow": []interface{}{"Read"}},
+		"model":       map[string]interface{}{"$literal": "x"},
+		"hooks":       map[string]interface{}{"Notification": []interface{}{}},
+	}
+	compareJSON(t, got, want)
+
+	// DeepMerge keeps nulls as values
+	if merged := DeepMerge(base, overlay); merged["alwaysThinkingEnabled"] != nil || len(merged) != 5 {
+		t.Errorf("DeepMerge() = %v, want nulls kept", merged)
+	}
+	// A literal is a single value, never merged key by key
+	merged := DeepMerge(base, map[string]interface{}{"statusLine": map[string]interface{}{"$literal": nil}})
+	if _, ok := literalValue(merged["statusLine"]); !ok {
+		t.Errorf("DeepMerge() statusLine = %v, want the literal", merged["statusLine"])
+	}
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SplitPath splits a dotted path such as "providers.glm.env.ANTHROPIC_MODEL"
+// into its keys. Returns an error for empty paths or empty segments.
+func SplitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path must not be empty")
+	}
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid path '%s': empty segment", path)
+		}
+	}
+	return keys, nil
+}
+
+// GetPath returns the value at path inside root.
+// The second return value reports whether the path exists.
+func GetPath(root map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = root
+	for _, key := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// SetPath sets the value at path inside root, creating intermediate maps as
+// needed (missing or null). Returns an error if an intermediate value exists
+// but is not a map.
+func SetPath(root map[string]interface{}, path []string, value interface{}) error {
+	if len(path) == 0 {
+		return fmt.Errorf("path must not be empty")
+	}
+
+	current := root
+	for i, key := range path[:len(path)-1] {
+		next, exists := current[key]
+		if !exists || next == nil {
+			child := make(map[string]interface{})
+			current[key] = child
+			current = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("'%s' is not an object", strings.Join(path[:i+1], "."))
+		}
+		current = child
+	}
+	current[path[len(path)-1]] = value
+	return nil
+}
+
+// UnsetPath removes the value at path inside root.
+// Reports whether a value was removed.
+func UnsetPath(root map[string]interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	parent, ok := GetPath(root, path[:len(path)-1])
+	if !ok {
+		return false
+	}
+	m, ok := parent.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, exists := m[path[len(path)-1]]; !exists {
+		return false
+	}
+	delete(m, path[len(path)-1])
+	return true
+}
+
+// Value types accepted by ParseValue.
+const (
+	ValueTypeAuto   = ""
+	ValueTypeString = "string"
+	ValueTypeNumber = "number"
+	ValueTypeBool   = "bool"
+	ValueTypeJSON   = "json"
+)
+
+// ParseValue converts a command-line value into a JSON value.
+//
+// With an explicit valueType the raw text is parsed as that type. Otherwise the
+// type is inferred:
+//   - if a value already exists at the path, its type is kept (so "30000" stays
+//     a string in env but becomes a number for a numeric setting);
+//   - values under an "env" map are always strings;
+//   - otherwise "true"/"false" are booleans, numbers are numbers, text starting
+//     with '{', '[' or '"' (or the word null) is a JSON literal, and anything
+//     else is a string.
+func ParseValue(raw, valueType string, path []string, existing interface{}, exists bool) (interface{}, error) {
+	if valueType == ValueTypeAuto {
+		valueType = inferValueType(raw, path, existing, exists)
+	}
+
+	switch valueType {
+	case ValueTypeString:
+		return raw, nil
+	case ValueTypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %s", raw)
+		}
+		return n, nil
+	case ValueTypeBool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool: %s", raw)
+		}
+		return b, nil
+	case ValueTypeJSON:
+		var v interface{}
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %w", err)
+		}
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unknown value type '%s' (want string, number, bool or json)", valueType)
+	}
+}
+
+// inferValueType picks the value type for ParseValue when none is given.
+func inferValueType(raw string, path []string, existing interface{}, exists bool) string {
+	if exists {
+		switch existing.(type) {
+		case string:
+			return ValueTypeString
+		case bool:
+			return ValueTypeBool
+		case float64:
+			return ValueTypeNumber
+		case map[string]interface{}, []interface{}:
+			return ValueTypeJSON
+		}
+	}
+
+	if len(path) >= 2 && path[len(path)-2] == "env" {
+		return ValueTypeString
+	}
+
+	if raw == "true" || raw == "false" {
+		return ValueTypeBool
+	}
+	if _, err := strconv.ParseFloat(raw, 64); err == nil {
+		return ValueTypeNumber
+	}
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "null" || strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, `"`) {
+		return ValueTypeJSON
+	}
+	return ValueTypeString
+}
+
+// IsSecretKey reports whether a key name looks like it holds a secret
+// (tokens, API keys, passwords).
+func IsSecretKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"TOKEN", "KEY", "SECRET", "PASSWORD"} {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSecret hides most of a secret value, keeping a short prefix and suffix
+// so users can still tell keys apart. Short values are hidden entirely.
+func MaskSecret(value string) string {
+	if len(value) < 12 {
+		return "****"
+	}
+	return value[:4] + "****" + value[len(value)-4:]
+}
+
+// MaskSecrets returns a copy of v where every string stored under a secret-looking
+// key is masked. key is the name v is stored under (empty for the root).
+func MaskSecrets(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			masked[k] = MaskSecrets(k, child)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(val))
+		for i, child := range val {
+			masked[i] = MaskSecrets(key, child)
+		}
+		return masked
+	case string:
+		if IsSecretKey(key) {
+			return MaskSecret(val)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// ToMap converts the configuration into a generic JSON tree.
+func ToMap(cfg *Config) (map[string]interface{}, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to convert config: %w", err)
+	}
+	return m, nil
+}
+
+// FromMap converts a generic JSON tree back into a configuration.
+// Returns an error for unknown top-level keys, values of the wrong type or
+// unknown merge strategies, so an edit can never produce a ccc.json that
+// fails to load.
+func FromMap(m map[string]interface{}) (*Config, error) {
+	known := knownConfigKeys()
+	for key := range m {
+		if !known[key] {
+			return nil, fmt.Errorf("unknown config key '%s'", key)
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config value: %w", err)
+	}
+	for path, strategy := range cfg.Merge {
+		if err := checkMergeStrategy(path, strategy); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
+
+// knownConfigKeys returns the top-level ccc.json keys, taken from the json
+// tags of Config so new fields are picked up automatically.
+func knownConfigKeys() map[string]bool {
+	known := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+	return known
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	got, err := SplitPath("providers.glm.env.ANTHROPIC_MODEL")
+	if err != nil {
+		t.Fatalf("SplitPath() error = %v", err)
+	}
+	want := []string{"providers", "glm", "env", "ANTHROPIC_MODEL"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitPath() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"", "a..b", ".a", "a."} {
+		if _, err := SplitPath(bad); err == nil {
+			t.Errorf("SplitPath(%q) should error", bad)
+		}
+	}
+}
+
+func TestGetSetUnsetPath(t *testing.T) {
+	root := map[string]interface{}{
+		"settings": nil,
+		"providers": map[string]interface{}{
+			"glm": map[string]interface{}{
+				"env": map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.7"},
+			},
+		},
+		"current_provider": "glm",
+	}
+
+	if v, ok := GetPath(root, []string{"providers", "glm", "env", "ANTHROPIC_MODEL"}); !ok || v != "glm-4.7" {
+		t.Errorf("GetPath() = %v, %v; want glm-4.7, true", v, ok)
+	}
+	if _, ok := GetPath(root, []string{"providers", "kimi"}); ok {
+		t.Error("GetPath() of missing key should report false")
+	}
+	if _, ok := GetPath(root, []string{"current_provider", "x"}); ok {
+		t.Error("GetPath() through a non-map should report false")
+	}
+
+	// Null and missing intermediates are created
+	if err := SetPath(root, []string{"settings", "permissions", "defaultMode"}, "acceptEdits"); err != nil {
+		t.Fatalf("SetPath() error = %v", err)
+	}
+	if v, _ := GetPath(root, []string{"settings", "permissions", "defaultMode"}); v != "acceptEdits" {
+		t.Errorf("after SetPath() value = %v, want acceptEdits", v)
+	}
+
+	err := SetPath(root, []string{"current_provider", "x"}, 1)
+	if err == nil || !strings.Contains(err.Error(), "'current_provider' is not an object") {
+		t.Errorf("SetPath() through a string error = %v", err)
+	}
+
+	if !UnsetPath(root, []string{"providers", "glm", "env", "ANTHROPIC_MODEL"}) {
+		t.Error("UnsetPath() should report removal")
+	}
+	if UnsetPath(root, []string{"providers", "glm", "env", "ANTHROPIC_MODEL"}) {
+		t.Error("UnsetPath() of missing key should report false")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	envPath := []string{"providers", "glm", "env", "API_TIMEOUT"}
+	settingPath := []string{"settings", "cleanupPeriodDays"}
+
+	tests := []struct {
+		name      string
+		raw       string
+		valueType string
+		path      []string
+		existing  interface{}
+		exists    bool
+		want      interface{}
+		wantErr   bool
+	}{
+		{name: "env value stays string", raw: "30000", path: envPath, want: "30000"},
+		{name: "inferred number", raw: "30", path: settingPath, want: float64(30)},
+		{name: "inferred bool", raw: "true", path: []string{"settings", "x"}, want: true},
+		{name: "inferred json", raw: `["Bash"]`, path: []string{"settings", "x"}, want: []interface{}{"Bash"}},
+		{name: "inferred string", raw: "glm-4.7", path: []string{"settings", "model"}, want: "glm-4.7"},
+		{name: "keeps existing string type", raw: "123", path: []string{"settings", "x"}, existing: "old", exists: true, want: "123"},
+		{name: "keeps existing number type", raw: "7", path: settingPath, existing: float64(30), exists: true, want: float64(7)},
+		{name: "explicit string", raw: "true", valueType: "string", path: []string{"settings", "x"}, want: "true"},
+		{name: "explicit number invalid", raw: "abc", valueType: "number", wantErr: true},
+		{name: "explicit bool invalid", raw: "maybe", valueType: "bool", wantErr: true},
+		{name: "explicit json invalid", raw: "{", valueType: "json", wantErr: true},
+		{name: "unknown type", raw: "x", valueType: "date", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseValue(tt.raw, tt.valueType, tt.path, tt.existing, tt.exists)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	if got := MaskSecret("sk-1234567890abcdef"); got != "sk-1****cdef" {
+		t.Errorf("MaskSecret() = %q, want sk-1****cdef", got)
+	}
+	if got := MaskSecret("short"); got != "****" {
+		t.Errorf("MaskSecret() = %q, want ****", got)
+	}
+
+	env := map[string]interface{}{
+		"ANTHROPIC_AUTH_TOKEN": "sk-1234567890abcdef",
+		"ANTHROPIC_MODEL":      "glm-4.7",
+	}
+	masked := MaskSecrets("env", env).(map[string]interface{})
+	if masked["ANTHROPIC_AUTH_TOKEN"] != "sk-1****cdef" {
+		t.Errorf("token = %v, want masked", masked["ANTHROPIC_AUTH_TOKEN"])
+	}
+	if masked["ANTHROPIC_MODEL"] != "glm-4.7" {
+		t.Errorf("model = %v, want unmasked", masked["ANTHROPIC_MODEL"])
+	}
+	if env["ANTHROPIC_AUTH_TOKEN"] != "sk-1234567890abcdef" {
+		t.Error("MaskSecrets() should not modify input")
+	}
+}
+
+func TestToMapFromMap(t *testing.T) {
+	cfg := &Config{
+		Settings:        map[string]interface{}{"model": "x"},
+		CurrentProvider: "glm",
+		Providers:       map[string]map[string]interface{}{"glm": {}},
+	}
+
+	m, err := ToMap(cfg)
+	if err != nil {
+		t.Fatalf("ToMap() error = %v", err)
+	}
+	back, err := FromMap(m)
+	if err != nil {
+		t.Fatalf("FromMap() error = %v", err)
+	}
+	if !reflect.DeepEqual(back, cfg) {
+		t.Errorf("round trip = %+v, want %+v", back, cfg)
+	}
+
+	m["claude_args"] = "--verbose"
+	if _, err := FromMap(m); err == nil {
+		t.Error("FromMap() should reject a string claude_args")
+	}
+
+	delete(m, "claude_args")
+	m["provider"] = "glm"
+	if _, err := FromMap(m); err == nil || !strings.Contains(err.Error(), "unknown config key 'provider'") {
+		t.Errorf("FromMap() error = %v, want unknown key", err)
+	}
+}
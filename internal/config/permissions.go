@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// PermissionCheck is the result of checking the permissions of a file or
+// directory ccc reads or writes.
+type PermissionCheck struct {
+	Path   string
+	Exists bool
+	Mode   os.FileMode
+	// Private is true for paths that may hold secrets: group and others must
+	// have no access at all. Other paths must only not be writable by them.
+	Private bool
+	// Sensitive is true for paths that should be private but hold no secrets
+	// now: access by group or others is only a warning.
+	Sensitive bool
+}
+
+// Problem describes what is wrong with the permissions, or "" if they are fine.
+func (c PermissionCheck) Problem() string {
+	if !c.Exists {
+		return ""
+	}
+	perm := c.Mode.Perm()
+	switch {
+	case perm&0022 != 0:
+		return "writable by group or others"
+	case c.Private && perm&0044 != 0:
+		return "readable by group or others"
+	case c.Private && perm&0077 != 0:
+		return "accessible by group or others"
+	}
+	return ""
+}
+
+// Warning describes access by group or others to a Sensitive path, or "" if
+// there is none or the permissions have a Problem.
+func (c PermissionCheck) Warning() string {
+	if !c.Exists || !c.Sensitive || c.Problem() != "" {
+		return ""
+	}
+	perm := c.Mode.Perm()
+	switch {
+	case perm&0044 != 0:
+		return "readable by group or others"
+	case perm&0077 != 0:
+		return "accessible by group or others"
+	}
+	return ""
+}
+
+// SuggestedMode returns the permissions the path should have.
+func (c PermissionCheck) SuggestedMode() os.FileMode {
+	if c.Private || c.Sensitive {
+		return c.Mode.Perm() &^ 0077
+	}
+	return c.Mode.Perm() &^ 0022
+}
+
+// CheckPermissions reports the permissions of the configuration directory and
+// the files and directories ccc keeps in it. Missing paths are included with
+// Exists set to false. Config fragments may be shared, so they only must not
+// be writable by others; so may ccc.json once its tokens live in the secrets
+// file. settings.json is only Private if its env holds a secret-looking value;
+// otherwise it is Sensitive.
+func CheckPermissions() ([]PermissionCheck, error) {
+	type entry struct {
+		path    string
+		private bool
+	}
+	_, err := os.Stat(GetSecretsPath())
+	hasSecrets := err == nil
+	paths := []entry{
+		{GetDir(), false},
+		{GetConfigPath(), !hasSecrets},
+		{GetSecretsPath(), true},
+		{GetFragmentDir(), false},
+	}
+	fragments, _ := filepath.Glob(filepath.Join(GetFragmentDir(), "*.json"))
+	sort.Strings(fragments)
+	for _, path := range fragments {
+		paths = append(paths, entry{path, false})
+	}
+	paths = append(paths,
+		entry{GetSettingsPath(), true},
+		entry{GetStateDir(), true},
+		entry{GetManagedPath(), true},
+		entry{GetHistoryDir(), true},
+		entry{GetSessionDir(), true},
+	)
+
+	settingsSecrets := settingsHoldSecrets()
+	checks := make([]PermissionCheck, 0, len(paths))
+	for _, p := range paths {
+		check := PermissionCheck{Path: p.path, Private: p.private}
+		if p.path == GetSettingsPath() && !settingsSecrets {
+			check.Private, check.Sensitive = false, true
+		}
+		info, err := os.Stat(p.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to check %s: %w", p.path, err)
+		}
+		if err == nil {
+			check.Exists = true
+			check.Mode = info.Mode()
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// settingsHoldSecrets reports whether the env of settings.json has a
+// non-empty value under a secret-looking key. A settings.json that can't be
+// read is assumed to hold secrets.
+func settingsHoldSecrets() bool {
+	settings, err := LoadSettings()
+	if err != nil {
+		return true
+	}
+	for key, value := range GetEnv(settings) {
+		if s, ok := value.(string); ok && s != "" && IsSecretKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokensExposed reports whether the file holding the API tokens is accessible
+// by group or others, and returns its path and permissions. That file is the
+// secrets file if there is one, ccc.json otherwise.
+func TokensExposed() (string, os.FileMode, bool) {
+	path := GetSecretsPath()
+	info, err := os.Stat(path)
+	if err != nil {
+		path = GetConfigPath()
+		if info, err = os.Stat(path); err != nil {
+			return "", 0, false
+		}
+	}
+	return path, info.Mode().Perm(), info.Mode().Perm()&0077 != 0
+}
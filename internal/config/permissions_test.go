@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPermissionCheckProblem(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   PermissionCheck
+		problem string
+		suggest os.FileMode
+	}{
+		{"private file", PermissionCheck{Exists: true, Mode: 0600, Private: true}, "", 0600},
+		{"readable secret", PermissionCheck{Exists: true, Mode: 0644, Private: true}, "readable by group or others", 0600},
+		{"group writable dir", PermissionCheck{Exists: true, Mode: os.ModeDir | 0775}, "writable by group or others", 0755},
+		{"readable public dir", PermissionCheck{Exists: true, Mode: os.ModeDir | 0755}, "", 0755},
+		{"private dir with group access", PermissionCheck{Exists: true, Mode: os.ModeDir | 0710, Private: true}, "accessible by group or others", 0700},
+		{"missing", PermissionCheck{Mode: 0777, Private: true}, "", 0700},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.check.Problem(); got != tt.problem {
+				t.Errorf("Problem() = %q, want %q", got, tt.problem)
+			}
+			if got := tt.check.SuggestedMode(); got != tt.suggest {
+				t.Errorf("SuggestedMode() = %04o, want %04o", got, tt.suggest)
+			}
+		})
+	}
+}
+
+func TestCheckPermissionsSettings(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	settingsCheck := func() PermissionCheck {
+		t.Helper()
+		checks, err := CheckPermissions()
+		if err != nil {
+			t.Fatalf("CheckPermissions() error = %v", err)
+		}
+		for _, check := range checks {
+			if check.Path == GetSettingsPath() {
+				return check
+			}
+		}
+		t.Fatal("settings.json is not checked")
+		return PermissionCheck{}
+	}
+
+	// Without secrets, a readable settings.json is only a warning
+	if err := SaveSettings(map[string]interface{}{"env": map[string]interface{}{"API_TIMEOUT": "30000"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(GetSettingsPath(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	check := settingsCheck()
+	if check.Problem() != "" || check.Warning() != "readable by group or others" || check.SuggestedMode() != 0600 {
+		t.Errorf("check = %+v: problem %q, warning %q, want only a warning", check, check.Problem(), check.Warning())
+	}
+
+	// With a token in its env, it is a problem
+	if err := SaveSettings(map[string]interface{}{"env": map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": "sk-x"}}); err != nil {
+		t.Fatal(err)
+	}
+	check = settingsCheck()
+	if check.Problem() != "readable by group or others" || check.Warning() != "" {
+		t.Errorf("check = %+v: problem %q, warning %q, want a problem", check, check.Problem(), check.Warning())
+	}
+}
+
+func TestSecureFileModes(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	mode := func(path string) os.FileMode {
+		t.Helper()
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	// New files are created private to the user
+	if err := Save(&Config{Providers: map[string]map[string]interface{}{"glm": {}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSettings(map[string]interface{}{"model": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveManaged(&ManagedRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{GetConfigPath(), GetSettingsPath(), GetManagedPath()} {
+		if got := mode(path); got != 0600 {
+			t.Errorf("%s mode = %04o, want 0600", path, got)
+		}
+	}
+	if got := mode(GetStateDir()); got != 0700 {
+		t.Errorf("state directory mode = %04o, want 0700", got)
+	}
+	if _, _, exposed := TokensExposed(); exposed {
+		t.Error("TokensExposed() = true for a 0600 ccc.json")
+	}
+
+	// Existing files keep their mode when rewritten
+	if err := os.Chmod(GetConfigPath(), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := Save(&Config{CurrentProvider: "glm"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := mode(GetConfigPath()); got != 0640 {
+		t.Errorf("rewritten ccc.json mode = %04o, want 0640 kept", got)
+	}
+	if path, got, exposed := TokensExposed(); !exposed || got != 0640 || path != GetConfigPath() {
+		t.Errorf("TokensExposed() = %s, %04o, %v, want ccc.json, 0640, true", path, got, exposed)
+	}
+
+	checks, err := CheckPermissions()
+	if err != nil {
+		t.Fatalf("CheckPermissions() error = %v", err)
+	}
+	problems := map[string]string{}
+	for _, check := range checks {
+		if problem := check.Problem(); problem != "" {
+			problems[check.Path] = problem
+		}
+	}
+	if len(problems) != 1 || problems[GetConfigPath()] != "readable by group or others" {
+		t.Errorf("CheckPermissions() problems = %v, want only ccc.json readable", problems)
+	}
+}
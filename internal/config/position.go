@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// PositionError is an error at a line and column of a JSON file.
+type PositionError struct {
+	Path   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Msg)
+}
+
+// newPositionError returns a PositionError for the byte offset in data.
+func newPositionError(path string, data []byte, offset int64, msg string) *PositionError {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	return &PositionError{
+		Path:   path,
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: utf8.RuneCount(before[lineStart:]) + 1,
+		Msg:    msg,
+	}
+}
+
+// locateParseError adds the line and column to a json.Unmarshal error of data.
+// Errors without a position are returned unchanged.
+func locateParseError(path string, data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Offset is just past the byte that caused the error
+		return newPositionError(path, data, syntaxErr.Offset-1, syntaxErr.Error())
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "the configuration"
+		}
+		msg := fmt.Sprintf("%s must be %s, not %s", field, describeType(typeErr.Type.Kind().String()), typeErr.Value)
+		offset := valueOffset(data, typeErr.Field)
+		if offset < 0 {
+			offset = typeErr.Offset
+		}
+		return newPositionError(path, data, offset, msg)
+	}
+	return err
+}
+
+// describeType returns a readable JSON name for a Go kind.
+func describeType(kind string) string {
+	switch kind {
+	case "map", "struct":
+		return "an object"
+	case "slice", "array":
+		return "an array"
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	}
+	return "a " + kind
+}
+
+// jsonKey is an object key found in a JSON document, with the offset of the
+// key itself.
+type jsonKey struct {
+	Path   []string
+	Offset int64
+}
+
+// walkJSON calls visit for every object key and array element in data, with
+// the path of the value and the offset where the key (or array element)
+// starts. It stops at the first syntax error.
+func walkJSON(data []byte, visit func(key jsonKey, valueOffset int64)) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	return walkValue(decoder, data, nil, visit)
+}
+
+func walkValue(decoder *json.Decoder, data []byte, path []string, visit func(jsonKey, int64)) error {
+	tok, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	for i := 0; decoder.More(); i++ {
+		var childPath []string
+		keyOffset := skipSeparators(data, decoder.InputOffset())
+		if delim == '{' {
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			childPath = append(append([]string{}, path...), key)
+		} else {
+			childPath = append(append([]string{}, path...), fmt.Sprint(i))
+		}
+		visit(jsonKey{Path: childPath, Offset: keyOffset}, skipSeparators(data, decoder.InputOffset()))
+		if err := walkValue(decoder, data, childPath, visit); err != nil {
+			return err
+		}
+	}
+
+	// Closing } or ]
+	_, err = decoder.Token()
+	return err
+}
+
+// skipSeparators returns the offset of the first byte at or after offset that
+// is not whitespace, a comma or a colon.
+func skipSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// valueOffset returns the offset where the value at the dotted field path
+// starts (as reported by json.UnmarshalTypeError), or -1 if it is not found.
+func valueOffset(data []byte, field string) int64 {
+	found := int64(-1)
+	walkJSON(data, func(key jsonKey, offset int64) {
+		if found < 0 && strings.Join(key.Path, ".") == field {
+			found = offset
+		}
+	})
+	return found
+}
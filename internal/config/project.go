@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ProjectFileName is the project file ccc looks for in the current directory
+// and its parents.
+const ProjectFileName = ".ccc.json"
+
+// ProjectConfig represents a project file (.ccc.json). It pins the provider
+// used inside a directory tree and may add settings and claude args on top of
+// the global ccc.json.
+type ProjectConfig struct {
+	Provider   string                 `json:"provider,omitempty"`
+	Settings   map[string]interface{} `json:"settings,omitempty"`
+	ClaudeArgs []string               `json:"claude_args,omitempty"`
+
+	// Path is the file this project config was loaded from.
+	Path string `json:"-"`
+	// Hash is the SHA-256 of the file content, hex encoded, used to record
+	// that the user trusts this content (see TrustProject).
+	Hash string `json:"-"`
+}
+
+// FindProjectConfig searches dir and each of its parents for a project file
+// and loads the nearest one. Returns nil if there is no project file.
+func FindProjectConfig(dir string) (*ProjectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return LoadProjectConfig(path)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to check project file: %w", err)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig reads and parses a project file.
+// Unknown keys are rejected so a typo cannot silently unpin the provider.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	var project ProjectConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&project); err != nil {
+		return nil, fmt.Errorf("failed to parse project file %s: %w", path, err)
+	}
+	project.Path = path
+	sum := sha256.Sum256(data)
+	project.Hash = hex.EncodeToString(sum[:])
+	return &project, nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b", "c")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("no project file", func(t *testing.T) {
+		project, err := FindProjectConfig(nested)
+		if err != nil {
+			t.Fatalf("FindProjectConfig() error = %v", err)
+		}
+		// A .ccc.json above the temp dir would be picked up here; only check the path
+		if project != nil && strings.HasPrefix(project.Path, root) {
+			t.Errorf("FindProjectConfig() = %+v, want none under %s", project, root)
+		}
+	})
+
+	rootFile := filepath.Join(root, ProjectFileName)
+	content := `{"provider": "internal", "settings": {"model": "opus"}, "claude_args": ["--verbose"]}`
+	if err := os.WriteFile(rootFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("found in a parent directory", func(t *testing.T) {
+		project, err := FindProjectConfig(nested)
+		if err != nil {
+			t.Fatalf("FindProjectConfig() error = %v", err)
+		}
+		if project == nil {
+			t.Fatal("FindProjectConfig() = nil, want project from root")
+		}
+		if project.Path != rootFile || project.Provider != "internal" {
+			t.Errorf("project = %+v, want provider internal from %s", project, rootFile)
+		}
+		if project.Settings["model"] != "opus" || len(project.ClaudeArgs) != 1 {
+			t.Errorf("project settings/args = %v / %v", project.Settings, project.ClaudeArgs)
+		}
+	})
+
+	t.Run("nearest file wins", func(t *testing.T) {
+		nearFile := filepath.Join(root, "a", ProjectFileName)
+		if err := os.WriteFile(nearFile, []byte(`{"provider": "glm"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(nearFile)
+
+		project, err := FindProjectConfig(nested)
+		if err != nil {
+			t.Fatalf("FindProjectConfig() error = %v", err)
+		}
+		if project == nil || project.Provider != "glm" || project.Path != nearFile {
+			t.Errorf("project = %+v, want provider glm from %s", project, nearFile)
+		}
+	})
+
+	t.Run("unknown keys are rejected", func(t *testing.T) {
+		badFile := filepath.Join(nested, ProjectFileName)
+		if err := os.WriteFile(badFile, []byte(`{"provder": "glm"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(badFile)
+
+		_, err := FindProjectConfig(nested)
+		if err == nil || !strings.Contains(err.Error(), "provder") {
+			t.Errorf("FindProjectConfig() error = %v, want unknown field error", err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed schema.json
+var schema []byte
+
+// Schema returns the JSON Schema describing ccc.json.
+func Schema() []byte {
+	return schema
+}
+
+// sortedConfigKeys returns the top-level keys allowed in ccc.json, sorted.
+func sortedConfigKeys() []string {
+	var keys []string
+	for key := range knownConfigKeys() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkUnknownKeys reports every top-level key of data that ccc.json does not
+// define, with its position and the closest known key as a suggestion.
+func checkUnknownKeys(path string, data []byte) error {
+	known := sortedConfigKeys()
+	allowed := knownConfigKeys()
+
+	var errs []error
+	walkJSON(data, func(key jsonKey, _ int64) {
+		if len(key.Path) != 1 || allowed[key.Path[0]] {
+			return
+		}
+		msg := fmt.Sprintf("unknown key %q", key.Path[0])
+		if suggestion := suggestKey(key.Path[0], known); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		errs = append(errs, newPositionError(path, data, key.Offset, msg))
+	})
+	return errors.Join(errs...)
+}
+
+// suggestKey returns the candidate closest to key, or "" if none is close
+// enough to be a likely typo.
+func suggestKey(key string, candidates []string) string {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		d := levenshtein(strings.ToLower(key), strings.ToLower(candidate))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	// Allow about one edit per three characters, and at least two
+	limit := len(key) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDistance < 0 || bestDistance > limit {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
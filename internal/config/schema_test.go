@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSchemaMatchesConfig(t *testing.T) {
+	var schema struct {
+		Properties map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	var properties []string
+	for key := range schema.Properties {
+		properties = append(properties, key)
+	}
+	sort.Strings(properties)
+	if !reflect.DeepEqual(properties, sortedConfigKeys()) {
+		t.Errorf("schema properties = %v, want the Config keys %v", properties, sortedConfigKeys())
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	known := sortedConfigKeys()
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"provider", "providers"},
+		{"claudeArgs", "claude_args"},
+		{"currentProvider", "current_provider"},
+		{"Settings", "settings"},
+		{"orders", "order"},
+		{"theme", ""},
+		{"model", ""},
+	}
+	for _, tt := range tests {
+		if got := suggestKey(tt.key, known); got != tt.want {
+			t.Errorf("suggestKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLoadReportsPositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown keys with suggestions",
+			content: "{\n  \"provider\": \"glm\",\n  \"providers\": {},\n    \"claudeArgs\": [],\n  \"theme\": 1\n}",
+			want: []string{
+				`ccc.json:2:3: unknown key "provider" (did you mean "providers"?)`,
+				`ccc.json:4:5: unknown key "claudeArgs" (did you mean "claude_args"?)`,
+				`ccc.json:5:3: unknown key "theme"` + "\n",
+			},
+		},
+		{
+			name:    "syntax error",
+			content: "{\n  \"providers\": {\"glm\": {} \"kimi\": {}}\n}",
+			want:    []string{"ccc.json:2:27: invalid character '\"' after object key:value pair"},
+		},
+		{
+			name:    "wrong type",
+			content: "{\n  \"providers\": {\n    \"glm\": \"oops\"\n  }\n}",
+			want:    []string{"ccc.json:3:12: providers.glm must be an object, not string"},
+		},
+		{
+			name:    "column counts characters",
+			content: "{\"settings\": {\"语言\": \"中文\"}, \"claude_args\": true}",
+			want:    []string{"ccc.json:1:43: claude_args must be an array, not bool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, cleanup := setupTestDir(t)
+			defer cleanup()
+
+			if err := os.WriteFile(GetConfigPath(), []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load()
+			if err == nil {
+				t.Fatal("Load() should fail")
+			}
+			msg := err.Error() + "\n"
+			for _, want := range tt.want {
+				if !strings.Contains(msg, want) {
+					t.Errorf("Load() error = %q, should contain %q", msg, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSchemaKeyIsKept(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	content := `{"$schema": "./ccc.schema.json", "providers": {"glm": {}}}`
+	if err := os.WriteFile(GetConfigPath(), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Schema != "./ccc.schema.json" {
+		t.Errorf("$schema = %q after save, want it kept", cfg.Schema)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secretCommandTimeout bounds how long a cmd: reference may run.
+const secretCommandTimeout = 30 * time.Second
+
+// IsSecretRef reports whether value is a secret reference (file:, env:, cmd:)
+// or an encrypted value (enc:v1:).
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, "file:") ||
+		strings.HasPrefix(value, "env:") ||
+		strings.HasPrefix(value, "cmd:") ||
+		IsEncrypted(value)
+}
+
+// ResolveSecretRef resolves a secret reference so tokens don't have to be
+// stored in plain text in ccc.json:
+//   - file:<path>    contents of the file, with surrounding whitespace trimmed
+//     ("~/" expands to the home directory)
+//   - env:<NAME>     value of the environment variable NAME (must be set)
+//   - cmd:<command>  standard output of `sh -c <command>`, trimmed
+//   - enc:v1:<data>  value decrypted with the passphrase (see Passphrase)
+//
+// Values without one of these prefixes are returned unchanged. Error messages
+// describe the reference only and never contain the resolved value.
+func ResolveSecretRef(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "file:"):
+		path := expandHome(strings.TrimPrefix(value, "file:"))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", value, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("%s: environment variable %s is not set", value, name)
+		}
+		return resolved, nil
+
+	case strings.HasPrefix(value, "cmd:"):
+		command := strings.TrimPrefix(value, "cmd:")
+		ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+		defer cancel()
+
+		c := exec.CommandContext(ctx, "sh", "-c", command)
+		var stdout bytes.Buffer
+		c.Stdout = &stdout
+		// Keep the terminal attached so tools like pass/gpg can prompt
+		c.Stdin = os.Stdin
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			return "", fmt.Errorf("%s: command failed: %w", value, err)
+		}
+		return strings.TrimSpace(stdout.String()), nil
+
+	case IsEncrypted(value):
+		passphrase, err := Passphrase()
+		if err != nil {
+			return "", fmt.Errorf("encrypted value: %w", err)
+		}
+		plaintext, err := DecryptSecret(value, passphrase)
+		if err != nil {
+			return "", fmt.Errorf("encrypted value: %w", err)
+		}
+		return plaintext, nil
+	}
+
+	return value, nil
+}
+
+// expandHome expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// EnvError lists every env value that could not be resolved.
+// It only carries descriptions of the references, never resolved values.
+type EnvError struct {
+	// Problems maps an env key to the reason it could not be resolved.
+	Problems map[string]string
+}
+
+func (e *EnvError) Error() string {
+	keys := make([]string, 0, len(e.Problems))
+	for key := range e.Problems {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("cannot resolve env values:")
+	for _, key := range keys {
+		b.WriteString(fmt.Sprintf("\n  - %s: %s", key, e.Problems[key]))
+	}
+	return b.String()
+}
+
+// ResolveEnv resolves every value of an env map for the claude subprocess:
+// ${VAR} references are expanded first (strictly, see ExpandEnvStrict), then
+// secret references (file:, env:, cmd:) and encrypted values are resolved.
+// Only values written as a reference are resolved: a variable expanding to
+// text that looks like one (e.g. "cmd:...") is used as is, never run.
+// All failures are collected into a single *EnvError; the returned map only
+// holds the values that resolved successfully.
+func ResolveEnv(env map[string]interface{}) (map[string]string, error) {
+	resolved := make(map[string]string, len(env))
+	problems := make(map[string]string)
+
+	for key, v := range env {
+		// A null unsets the variable (see MergePatch)
+		if v == nil {
+			continue
+		}
+		raw := fmt.Sprintf("%v", v)
+		value, unresolved := ExpandEnvStrict(raw, os.LookupEnv)
+		if len(unresolved) > 0 {
+			problems[key] = strings.Join(unresolved, "; ")
+			continue
+		}
+		if IsSecretRef(raw) {
+			var err error
+			if value, err = ResolveSecretRef(value); err != nil {
+				problems[key] = err.Error()
+				continue
+			}
+		}
+		resolved[key] = value
+	}
+
+	if len(problems) > 0 {
+		return resolved, &EnvError{Problems: problems}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecretRef(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "glm")
+	if err := os.WriteFile(secretFile, []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CCC_TEST_SECRET", "sk-from-env")
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "plain value", value: "sk-plain", want: "sk-plain"},
+		{name: "file reference", value: "file:" + secretFile, want: "sk-from-file"},
+		{name: "env reference", value: "env:CCC_TEST_SECRET", want: "sk-from-env"},
+		{name: "cmd reference", value: "cmd:printf 'sk-from-cmd\\n'", want: "sk-from-cmd"},
+		{name: "missing file", value: "file:" + filepath.Join(dir, "missing"), wantErr: "no such file"},
+		{name: "unset env", value: "env:CCC_TEST_UNSET_SECRET", wantErr: "is not set"},
+		{name: "failing cmd", value: "cmd:echo sk-leak; exit 3", wantErr: "command failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSecretRef(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveSecretRef() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveSecretRef() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveSecretRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSecretRefHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.WriteFile(filepath.Join(home, "token"), []byte("sk-home"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ResolveSecretRef("file:~/token")
+	if err != nil || got != "sk-home" {
+		t.Errorf("ResolveSecretRef(file:~/token) = %q, %v; want sk-home", got, err)
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("CCC_TEST_SECRET", "sk-from-env")
+	t.Setenv("CCC_TEST_HOST", "example.com")
+
+	resolved, err := ResolveEnv(map[string]interface{}{
+		"ANTHROPIC_BASE_URL":   "https://${CCC_TEST_HOST}/anthropic",
+		"ANTHROPIC_AUTH_TOKEN": "env:CCC_TEST_SECRET",
+		"API_TIMEOUT":          30000,
+	})
+	if err != nil {
+		t.Fatalf("ResolveEnv() error = %v", err)
+	}
+	if resolved["ANTHROPIC_BASE_URL"] != "https://example.com/anthropic" {
+		t.Errorf("BASE_URL = %q", resolved["ANTHROPIC_BASE_URL"])
+	}
+	if resolved["ANTHROPIC_AUTH_TOKEN"] != "sk-from-env" {
+		t.Errorf("AUTH_TOKEN = %q", resolved["ANTHROPIC_AUTH_TOKEN"])
+	}
+	if resolved["API_TIMEOUT"] != "30000" {
+		t.Errorf("API_TIMEOUT = %q", resolved["API_TIMEOUT"])
+	}
+
+	// Expanded text is never treated as a reference
+	marker := filepath.Join(t.TempDir(), "ran")
+	t.Setenv("CCC_TEST_INJECTED", "cmd:touch "+marker)
+	resolved, err = ResolveEnv(map[string]interface{}{"ANTHROPIC_AUTH_TOKEN": "${CCC_TEST_INJECTED}"})
+	if err != nil {
+		t.Fatalf("ResolveEnv() error = %v", err)
+	}
+	if resolved["ANTHROPIC_AUTH_TOKEN"] != "cmd:touch "+marker {
+		t.Errorf("AUTH_TOKEN = %q, want the expanded text", resolved["ANTHROPIC_AUTH_TOKEN"])
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Error("a command from an expanded variable must not run")
+	}
+
+	_, err = ResolveEnv(map[string]interface{}{
+		"ANTHROPIC_AUTH_TOKEN": "env:CCC_TEST_UNSET_A",
+		"OTHER_KEY":            "cmd:exit 1",
+		"ANTHROPIC_BASE_URL":   "https://${CCC_TEST_UNSET_HOST}",
+		"FINE":                 "value",
+	})
+	var envErr *EnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("ResolveEnv() error = %v, want *EnvError", err)
+	}
+	if len(envErr.Problems) != 3 {
+		t.Errorf("Problems = %v, want 3 entries", envErr.Problems)
+	}
+	if envErr.Problems["ANTHROPIC_BASE_URL"] != "${CCC_TEST_UNSET_HOST} is not set" {
+		t.Errorf("BASE_URL problem = %q", envErr.Problems["ANTHROPIC_BASE_URL"])
+	}
+	msg := err.Error()
+	if strings.Index(msg, "ANTHROPIC_AUTH_TOKEN") > strings.Index(msg, "OTHER_KEY") {
+		t.Errorf("problems should be listed in key order, got: %s", msg)
+	}
+}
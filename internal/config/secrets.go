@@ -0,0 +1,221 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/jsonc"
+)
+
+// SecretsFileName is the file next to ccc.json holding the secret env values
+// of providers, so ccc.json itself can be shared without tokens.
+const SecretsFileName = "ccc.secrets.json"
+
+// GetSecretsPath returns the path of the secrets file.
+func GetSecretsPath() string {
+	return filepath.Join(GetDir(), SecretsFileName)
+}
+
+// IsSplitSecret reports whether an env value should live in the secrets file:
+// its key ends in _TOKEN or _KEY and it holds the secret itself rather than a
+// reference to it.
+func IsSplitSecret(key string, value interface{}) bool {
+	s, ok := value.(string)
+	if !ok || s == "" || IsSecretRef(s) || strings.HasPrefix(s, "$") {
+		return false
+	}
+	upper := strings.ToUpper(key)
+	return strings.HasSuffix(upper, "_TOKEN") || strings.HasSuffix(upper, "_KEY")
+}
+
+// loadSecrets reads the secrets file. It returns nil if the file doesn't
+// exist. The file has the shape {"providers": {"<name>": {"env": {...}}}}
+// with string values; anything else is an error with its position.
+func loadSecrets() (map[string]interface{}, error) {
+	path := GetSecretsPath()
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	data := jsonc.Strip(raw)
+
+	var secrets map[string]interface{}
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets file: %w", locateParseError(path, data, err))
+	}
+
+	var errs []error
+	walkJSON(data, func(key jsonKey, valueOffset int64) {
+		value, _ := GetPath(secrets, key.Path)
+		var msg string
+		switch len(key.Path) {
+		case 1:
+			if key.Path[0] != "providers" && key.Path[0] != "$schema" {
+				msg = fmt.Sprintf("unknown key %q (the secrets file only holds providers.<name>.env)", key.Path[0])
+			}
+		case 2:
+			if _, ok := value.(map[string]interface{}); !ok && key.Path[0] == "providers" {
+				msg = fmt.Sprintf("provider '%s' must be an object", key.Path[1])
+			}
+		case 3:
+			if key.Path[2] != "env" {
+				msg = fmt.Sprintf("unknown key %q (the secrets file only holds providers.<name>.env)", key.Path[2])
+			} else if _, ok := value.(map[string]interface{}); !ok {
+				msg = "env must be an object"
+			}
+		case 4:
+			if _, ok := value.(string); !ok {
+				msg = fmt.Sprintf("%s must be a string", strings.Join(key.Path, "."))
+			}
+		}
+		if msg != "" {
+			errs = append(errs, newPositionError(path, data, key.Offset, msg))
+		}
+	})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid secrets file: %w", errors.Join(errs...))
+	}
+	if secrets == nil {
+		secrets = make(map[string]interface{})
+	}
+	return secrets, nil
+}
+
+// checkSecretProviders returns an error if the secrets file has values for a
+// provider that is not defined in the configuration.
+func checkSecretProviders(secrets, tree map[string]interface{}) error {
+	secretProviders, _ := secrets["providers"].(map[string]interface{})
+	providers, _ := tree["providers"].(map[string]interface{})
+	var unknown []string
+	for name := range secretProviders {
+		if _, exists := providers[name]; !exists {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s has secrets for unknown provider(s): %s", GetSecretsPath(), strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// splitSecretValues moves the provider env values that belong in the secrets
+// file out of full (the whole configuration as a tree) and returns them as a
+// secrets tree. A value belongs there if the secrets file already holds it,
+// or if it is a new secret (see IsSplitSecret) that is neither in ccc.json
+// (given as previous) nor in base (the fragments). Moved values that a
+// fragment defines are reset to the fragment's value in full, so they are not
+// seen as removed.
+func splitSecretValues(full, secrets, previous, base map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"providers": map[string]interface{}{}}
+	providers, _ := full["providers"].(map[string]interface{})
+	for name, p := range providers {
+		settings, _ := p.(map[string]interface{})
+		env, _ := settings["env"].(map[string]interface{})
+		for key, value := range env {
+			path := []string{"providers", name, "env", key}
+			_, inSecrets := GetPath(secrets, path)
+			_, inPrevious := GetPath(previous, path)
+			baseValue, inBase := GetPath(base, path)
+			if !inSecrets && (inPrevious || inBase || !IsSplitSecret(key, value)) {
+				continue
+			}
+			_ = SetPath(result, path, value)
+			if inBase {
+				env[key] = baseValue
+			} else {
+				delete(env, key)
+			}
+		}
+		if len(env) == 0 {
+			if _, inPrevious := GetPath(previous, []string{"providers", name, "env"}); !inPrevious {
+				delete(settings, "env")
+			}
+		}
+	}
+	return result
+}
+
+// saveSecrets writes the secrets file, keeping its comments and formatting
+// like Save does for ccc.json.
+func saveSecrets(secrets map[string]interface{}) error {
+	path := GetSecretsPath()
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	if existing, err := os.ReadFile(path); err == nil {
+		if patched, err := jsonc.Patch(existing, data); err == nil {
+			if bytes.Equal(patched, existing) {
+				return nil
+			}
+			data = patched
+		}
+	}
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
+	return nil
+}
+
+// readMainTree returns the content of ccc.json alone, without fragments or
+// secrets, or nil if it doesn't exist.
+func readMainTree() (map[string]interface{}, error) {
+	raw, err := os.ReadFile(GetConfigPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var main map[string]interface{}
+	if err := json.Unmarshal(jsonc.Strip(raw), &main); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return main, nil
+}
+
+// SplitSecrets marks the secret env values of the providers in ccc.json (see
+// IsSplitSecret) to be moved to the secrets file by the next Save, creating
+// the secrets file if needed. Values from fragments are left alone. Returns
+// the paths of the moved values, sorted.
+func SplitSecrets(cfg *Config) ([]string, error) {
+	main, err := readMainTree()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Secrets == nil {
+		cfg.Secrets = make(map[string]interface{})
+	}
+	var moved []string
+	providers, _ := main["providers"].(map[string]interface{})
+	for name, p := range providers {
+		settings, _ := p.(map[string]interface{})
+		env, _ := settings["env"].(map[string]interface{})
+		for key, value := range env {
+			path := []string{"providers", name, "env", key}
+			if _, inSecrets := GetPath(cfg.Secrets, path); inSecrets || !IsSplitSecret(key, value) {
+				continue
+			}
+			// The merged value is what ccc uses; it equals value unless the
+			// secrets file overrides it
+			current, _ := GetPath(cfg.Providers[name], []string{"env", key})
+			if err := SetPath(cfg.Secrets, path, current); err != nil {
+				return nil, err
+			}
+			moved = append(moved, strings.Join(path, "."))
+		}
+	}
+	sort.Strings(moved)
+	return moved, nil
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(GetConfigPath(), `{"providers": {"glm": {"env": {"ANTHROPIC_BASE_URL": "https://glm", "ANTHROPIC_AUTH_TOKEN": "placeholder"}}}}`)
+	writeFile(GetSecretsPath(), `{
+  // not shared
+  "providers": {"glm": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-secret"}}},
+}`)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := GetAuthToken(cfg.Providers["glm"]); got != "sk-secret" {
+		t.Errorf("token = %q, want sk-secret from the secrets file", got)
+	}
+	if got := GetBaseURL(cfg.Providers["glm"]); got != "https://glm" {
+		t.Errorf("base URL = %q, want https://glm from ccc.json", got)
+	}
+	if cfg.Secrets == nil {
+		t.Error("Secrets = nil, want the secrets file content")
+	}
+
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{`{"settings": {}}`, `ccc.secrets.json:1:2: unknown key "settings"`},
+		{`{"providers": {"glm": {"env": {"API_TIMEOUT_MS": 3000}}}}`, "providers.glm.env.API_TIMEOUT_MS must be a string"},
+		{`{"providers": {"glm": {"model": "x"}}}`, `unknown key "model"`},
+		{`{"providers": {"kimi": {"env": {}}}}`, "secrets for unknown provider(s): kimi"},
+	}
+	for _, tt := range tests {
+		writeFile(GetSecretsPath(), tt.content)
+		_, err := Load()
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Load() with %s error = %v, want %q", tt.content, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSaveWithSecrets(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := os.WriteFile(GetConfigPath(), []byte(`{"providers": {"glm": {"env": {"ANTHROPIC_BASE_URL": "https://glm"}}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GetSecretsPath(), []byte(`{"providers": {"glm": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-old"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.CurrentProvider = "glm"
+	cfg.Providers["glm"]["env"].(map[string]interface{})["ANTHROPIC_AUTH_TOKEN"] = "sk-new"
+	cfg.Providers["kimi"] = map[string]interface{}{"env": map[string]interface{}{
+		"ANTHROPIC_BASE_URL":   "https://kimi",
+		"ANTHROPIC_AUTH_TOKEN": "sk-kimi",
+		"ANTHROPIC_API_KEY":    "env:KIMI_KEY",
+	}}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	main, _ := os.ReadFile(GetConfigPath())
+	if strings.Contains(string(main), "sk-") {
+		t.Errorf("ccc.json holds a token:\n%s", main)
+	}
+	if !strings.Contains(string(main), `"current_provider": "glm"`) || !strings.Contains(string(main), "env:KIMI_KEY") {
+		t.Errorf("ccc.json misses the shared values:\n%s", main)
+	}
+	secrets, _ := os.ReadFile(GetSecretsPath())
+	if !strings.Contains(string(secrets), "sk-new") || !strings.Contains(string(secrets), "sk-kimi") {
+		t.Errorf("secrets file misses the tokens:\n%s", secrets)
+	}
+
+	// ccc.json may be shared once the tokens are in the secrets file
+	if path, _, exposed := TokensExposed(); exposed {
+		t.Errorf("TokensExposed() = true for %s, want only the 0600 secrets file checked", path)
+	}
+
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GetAuthToken(reloaded.Providers["kimi"]); got != "sk-kimi" {
+		t.Errorf("reloaded kimi token = %q, want sk-kimi", got)
+	}
+}
+
+func TestSplitSecrets(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	main := `{
+  "providers": {
+    "glm": {
+      "env": {
+        "ANTHROPIC_BASE_URL": "https://glm",
+        "ANTHROPIC_AUTH_TOKEN": "sk-glm" // personal
+      }
+    },
+    "kimi": {"env": {"ANTHROPIC_AUTH_TOKEN": "env:KIMI_TOKEN", "SEARCH_API_KEY": "abc"}}
+  }
+}`
+	if err := os.WriteFile(GetConfigPath(), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := SplitSecrets(cfg)
+	if err != nil {
+		t.Fatalf("SplitSecrets() error = %v", err)
+	}
+	want := "providers.glm.env.ANTHROPIC_AUTH_TOKEN providers.kimi.env.SEARCH_API_KEY"
+	if got := strings.Join(moved, " "); got != want {
+		t.Errorf("SplitSecrets() = %s, want %s", got, want)
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	wantMain := `{
+  "providers": {
+    "glm": {
+      "env": {
+        "ANTHROPIC_BASE_URL": "https://glm"
+      }
+    },
+    "kimi": {"env": {"ANTHROPIC_AUTH_TOKEN": "env:KIMI_TOKEN"}}
+  }
+}`
+	if got, _ := os.ReadFile(GetConfigPath()); string(got) != wantMain {
+		t.Errorf("ccc.json =\n%s\nwant\n%s", got, wantMain)
+	}
+	info, err := os.Stat(GetSecretsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("secrets file mode = %04o, want 0600", info.Mode().Perm())
+	}
+
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GetAuthToken(reloaded.Providers["glm"]); got != "sk-glm" {
+		t.Errorf("glm token = %q, want sk-glm", got)
+	}
+	if moved, _ := SplitSecrets(reloaded); len(moved) != 0 {
+		t.Errorf("second SplitSecrets() = %v, want nothing", moved)
+	}
+}
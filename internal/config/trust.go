@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A project file comes with the directory tree it sits in, e.g. a cloned
+// repository, so its settings and claude_args are only applied once the user
+// trusted the file. Trust is recorded per path with the hash of the content
+// that was trusted: any change to the file has to be trusted again.
+
+// GetTrustPath returns the file recording the trusted project files.
+func GetTrustPath() string {
+	return filepath.Join(GetStateDir(), "trusted-projects.json")
+}
+
+// loadTrusted returns the trusted project files: the hash trusted, by path.
+func loadTrusted() (map[string]string, error) {
+	data, err := os.ReadFile(GetTrustPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted project files: %w", err)
+	}
+	trusted := map[string]string{}
+	if err := json.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", GetTrustPath(), err)
+	}
+	return trusted, nil
+}
+
+// IsProjectTrusted reports whether project was trusted with its current
+// content. A project file that only pins the provider needs no trust: it
+// can only select one of the user's own providers.
+func IsProjectTrusted(project *ProjectConfig) (bool, error) {
+	if len(project.Settings) == 0 && len(project.ClaudeArgs) == 0 {
+		return true, nil
+	}
+	trusted, err := loadTrusted()
+	if err != nil {
+		return false, err
+	}
+	return trusted[project.Path] == project.Hash, nil
+}
+
+// TrustProject records project as trusted with its current content.
+func TrustProject(project *ProjectConfig) error {
+	trusted, err := loadTrusted()
+	if err != nil {
+		return err
+	}
+	trusted[project.Path] = project.Hash
+	data, err := json.MarshalIndent(trusted, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trusted project files: %w", err)
+	}
+	if err := os.MkdirAll(GetStateDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := WriteFileAtomic(GetTrustPath(), data, 0600); err != nil {
+		return fmt.Errorf("failed to write trusted project files: %w", err)
+	}
+	return nil
+}
+
+// Untrusted returns the part of project applied while it isn't trusted: the
+// provider pin only.
+func (p *ProjectConfig) Untrusted() *ProjectConfig {
+	return &ProjectConfig{Provider: p.Provider, Path: p.Path, Hash: p.Hash}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrustProject(t *testing.T) {
+	_, cleanup := setupTestDir(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), ProjectFileName)
+	write := func(content string) *ProjectConfig {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		project, err := LoadProjectConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return project
+	}
+	isTrusted := func(project *ProjectConfig) bool {
+		trusted, err := IsProjectTrusted(project)
+		if err != nil {
+			t.Fatalf("IsProjectTrusted() error = %v", err)
+		}
+		return trusted
+	}
+
+	// Pinning the provider needs no trust
+	if project := write(`{"provider": "glm"}`); !isTrusted(project) {
+		t.Error("a project file that only pins the provider should be trusted")
+	}
+
+	project := write(`{"provider": "glm", "claude_args": ["--dangerously-skip-permissions"]}`)
+	if isTrusted(project) {
+		t.Fatal("a new project file should not be trusted")
+	}
+	if untrusted := project.Untrusted(); untrusted.Provider != "glm" || len(untrusted.ClaudeArgs) != 0 {
+		t.Errorf("Untrusted() = %+v, want the provider pin only", untrusted)
+	}
+	if err := TrustProject(project); err != nil {
+		t.Fatalf("TrustProject() error = %v", err)
+	}
+	if !isTrusted(project) {
+		t.Error("project file should be trusted after TrustProject()")
+	}
+
+	// Changing the file requires trusting it again
+	if changed := write(`{"provider": "glm", "claude_args": ["--verbose"]}`); isTrusted(changed) {
+		t.Error("a changed project file should not be trusted")
+	}
+}
@@ -0,0 +1,62 @@
+// Package jsonc handles JSON with comments: `//` line comments, `/* */` block
+// comments and trailing commas in objects and arrays.
+package jsonc
+
+// Strip returns data as plain JSON. Comments and trailing commas are replaced
+// with spaces (newlines inside block comments are kept), so byte offsets, lines
+// and columns in the result match the original.
+func Strip(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	// lastComma is the offset of a comma not yet followed by a value
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			lastComma = -1
+			i = skipString(out, i)
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' && out[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			lastComma = -1
+		}
+	}
+	return out
+}
+
+// skipString returns the offset of the quote closing the string that starts at
+// start, or the last offset if the string is unterminated.
+func skipString(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data) - 1
+}
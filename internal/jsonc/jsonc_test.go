@@ -0,0 +1,60 @@
+package jsonc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "line comment",
+			input: "{\"a\": 1 // note\n}",
+			want:  "{\"a\": 1        \n}",
+		},
+		{
+			name:  "block comment keeps newlines",
+			input: "{/* one\ntwo */\"a\": 1}",
+			want:  "{      \n      \"a\": 1}",
+		},
+		{
+			name:  "trailing commas",
+			input: "{\"a\": [1, 2,], \"b\": {\"c\": 3,},}",
+			want:  "{\"a\": [1, 2 ], \"b\": {\"c\": 3 } }",
+		},
+		{
+			name:  "trailing comma before a comment",
+			input: "[1, // last\n]",
+			want:  "[1         \n]",
+		},
+		{
+			name:  "comment markers inside strings are kept",
+			input: `{"url": "https://x/*y*/", "s": "a\"//b,"}`,
+			want:  `{"url": "https://x/*y*/", "s": "a\"//b,"}`,
+		},
+		{
+			name:  "plain JSON is unchanged",
+			input: "{\"a\": [1, {\"b\": null}]}",
+			want:  "{\"a\": [1, {\"b\": null}]}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Strip([]byte(tt.input))
+			if string(got) != tt.want {
+				t.Errorf("Strip() = %q, want %q", got, tt.want)
+			}
+			if len(got) != len(tt.input) {
+				t.Errorf("Strip() changed the length from %d to %d", len(tt.input), len(got))
+			}
+			if !json.Valid(got) {
+				t.Errorf("Strip() = %q is not valid JSON", got)
+			}
+		})
+	}
+}
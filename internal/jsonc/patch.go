@@ -0,0 +1,403 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Patch returns original (JSON or JSONC) changed to hold the value of the JSON
+// document updated, touching only the parts that differ: unchanged values keep
+// their formatting and comments, changed values are rewritten in place,
+// removed object members are deleted and new members are appended to their
+// object. An error is returned if original cannot be parsed.
+func Patch(original, updated []byte) ([]byte, error) {
+	stripped := Strip(original)
+	p := &parser{data: stripped}
+	root, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	value, err := decode(updated)
+	if err != nil {
+		return nil, err
+	}
+
+	pt := &patcher{original: original, data: stripped, indent: detectIndent(original)}
+	if err := pt.patch(root, value); err != nil {
+		return nil, err
+	}
+
+	// Apply edits from the end, so earlier offsets stay valid. At the same
+	// offset, a deletion goes before an insertion made where it starts.
+	sort.SliceStable(pt.edits, func(i, j int) bool {
+		if pt.edits[i].start != pt.edits[j].start {
+			return pt.edits[i].start > pt.edits[j].start
+		}
+		return pt.edits[i].end > pt.edits[j].end
+	})
+	result := append([]byte{}, original...)
+	for _, e := range pt.edits {
+		result = append(result[:e.start], append([]byte(e.text), result[e.end:]...)...)
+	}
+	return result, nil
+}
+
+// decode parses JSON into generic values, keeping numbers as written.
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// node is a parsed JSON value with its byte span in the document.
+type node struct {
+	start, end int
+	members    []member // object members; nil for other values
+	isObject   bool
+}
+
+// member is an object member: the key, where the key starts and its value.
+type member struct {
+	key      string
+	keyStart int
+	value    *node
+}
+
+// parser parses a comment-free JSON document into nodes.
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) parseDocument() (*node, error) {
+	n, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, p.errorf("unexpected content after the document")
+	}
+	return n, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid JSON at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) && strings.IndexByte(" \t\r\n", p.data[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) parseValue() (*node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	start := p.pos
+	switch p.data[p.pos] {
+	case '{':
+		return p.parseObject()
+	case '[':
+		p.pos++
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return &node{start: start, end: p.pos}, nil
+			}
+			if _, err := p.parseValue(); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ',' {
+				p.pos++
+			}
+		}
+	case '"':
+		p.pos = skipString(p.data, p.pos) + 1
+	default:
+		for p.pos < len(p.data) && strings.IndexByte(" \t\r\n,]}", p.data[p.pos]) < 0 {
+			p.pos++
+		}
+	}
+	n := &node{start: start, end: p.pos}
+	var v interface{}
+	if err := json.Unmarshal(p.data[n.start:n.end], &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON value at offset %d: %w", start, err)
+	}
+	return n, nil
+}
+
+func (p *parser) parseObject() (*node, error) {
+	n := &node{start: p.pos, isObject: true}
+	p.pos++
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unterminated object")
+		}
+		if p.data[p.pos] == '}' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+		if p.data[p.pos] != '"' {
+			return nil, p.errorf("expected an object key")
+		}
+		keyStart := p.pos
+		p.pos = skipString(p.data, p.pos) + 1
+		var key string
+		if err := json.Unmarshal(p.data[keyStart:p.pos], &key); err != nil {
+			return nil, p.errorf("invalid object key")
+		}
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after object key")
+		}
+		p.pos++
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, member{key: key, keyStart: keyStart, value: value})
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ',' {
+			p.pos++
+		}
+	}
+}
+
+// edit replaces data[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// patcher collects the edits turning a parsed document into a new value.
+// data is the document with comments stripped; original still has them.
+type patcher struct {
+	original []byte
+	data     []byte
+	indent   string
+	edits    []edit
+}
+
+func (pt *patcher) patch(n *node, value interface{}) error {
+	old, err := decode(pt.data[n.start:n.end])
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(old, value) {
+		return nil
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !n.isObject || !ok || len(n.members) == 0 {
+		return pt.replace(n, value)
+	}
+
+	// Patch the members that are kept
+	mark := len(pt.edits)
+	lastKept := -1
+	kept := make(map[int]bool)
+	for i, m := range n.members {
+		newValue, exists := object[m.key]
+		if !exists || pt.hasKey(n, i) {
+			continue
+		}
+		kept[i] = true
+		lastKept = i
+		if err := pt.patch(m.value, newValue); err != nil {
+			return err
+		}
+	}
+	if lastKept < 0 {
+		// Every member is removed; rewrite the whole object instead
+		pt.edits = pt.edits[:mark]
+		return pt.replace(n, value)
+	}
+
+	// Delete the other members
+	for i := range n.members[:lastKept] {
+		if !kept[i] {
+			pt.edits = append(pt.edits, pt.deleteMember(n, i))
+		}
+	}
+	if lastKept < len(n.members)-1 {
+		pt.edits = append(pt.edits, pt.deleteTrailing(n, lastKept)...)
+	}
+	last := n.members[lastKept].value.end
+
+	// Append new members after the last kept member, on lines of their own
+	// unless the object is written on a single line
+	var added []string
+	for key := range object {
+		if _, exists := pt.memberIndex(n, key); !exists {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	singleLine := bytes.IndexByte(pt.data[n.start:n.end], '\n') < 0
+	childIndent := pt.lineIndent(n.members[0].keyStart)
+	if !pt.startsLine(n.members[0].keyStart) {
+		childIndent = pt.lineIndent(n.start) + pt.indent
+	}
+	var text strings.Builder
+	for _, key := range added {
+		keyJSON, _ := json.Marshal(key)
+		if singleLine {
+			encoded, err := pt.encode(object[key], "", "")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&text, ", %s: %s", keyJSON, encoded)
+			continue
+		}
+		encoded, err := pt.encode(object[key], childIndent, pt.indent)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&text, ",\n%s%s: %s", childIndent, keyJSON, encoded)
+	}
+	if text.Len() > 0 {
+		pt.edits = append(pt.edits, edit{start: last, end: last, text: text.String()})
+	}
+	return nil
+}
+
+// hasKey reports whether the key of member i already appeared earlier in n.
+// Only the first of duplicate keys is kept.
+func (pt *patcher) hasKey(n *node, i int) bool {
+	first, _ := pt.memberIndex(n, n.members[i].key)
+	return first != i
+}
+
+// memberIndex returns the index of the first member of n with the given key.
+func (pt *patcher) memberIndex(n *node, key string) (int, bool) {
+	for i, m := range n.members {
+		if m.key == key {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// replace rewrites the whole value of n, indented to match its line.
+func (pt *patcher) replace(n *node, value interface{}) error {
+	encoded, err := pt.encode(value, pt.lineIndent(n.start), pt.indent)
+	if err != nil {
+		return err
+	}
+	pt.edits = append(pt.edits, edit{start: n.start, end: n.end, text: encoded})
+	return nil
+}
+
+// deleteMember returns the edit removing member i of object n, which is
+// followed by another member, together with its comma. A member on a line of
+// its own is deleted with the whole line, including a comment after the comma
+// and comment lines right above it.
+func (pt *patcher) deleteMember(n *node, i int) edit {
+	m := n.members[i]
+	comma := m.value.end + bytes.IndexByte(pt.data[m.value.end:], ',')
+	end := comma + 1
+	for end < len(pt.data) && strings.IndexByte(" \t\r", pt.data[end]) >= 0 {
+		end++
+	}
+	if !pt.startsLine(m.keyStart) || end >= len(pt.data) || pt.data[end] != '\n' {
+		return edit{start: m.keyStart, end: end}
+	}
+
+	start := bytes.LastIndexByte(pt.data[:m.keyStart], '\n') + 1
+	for start > 0 {
+		prev := bytes.LastIndexByte(pt.data[:start-1], '\n') + 1
+		commentOnly := len(bytes.TrimSpace(pt.data[prev:start])) == 0 &&
+			len(bytes.TrimSpace(pt.original[prev:start])) > 0
+		if !commentOnly {
+			break
+		}
+		start = prev
+	}
+	return edit{start: start, end: end + 1}
+}
+
+// deleteTrailing returns the edits removing the members of object n after
+// member lastKept. If they are on lines of their own, the comma of the kept
+// member is removed (unless the object uses a trailing comma) and their lines
+// go like in deleteMember, comments included; otherwise they go in one edit from the end of the kept member.
+func (pt *patcher) deleteTrailing(n *node, lastKept int) []edit {
+	keptEnd := n.members[lastKept].value.end
+	first := n.members[lastKept+1]
+	lastEnd := n.members[len(n.members)-1].value.end
+	lineEnd := lastEnd + bytes.IndexByte(pt.data[lastEnd:], '\n')
+	if !pt.startsLine(first.keyStart) || lineEnd < lastEnd || len(bytes.Trim(pt.data[lastEnd:lineEnd], " \t\r,")) > 0 {
+		return []edit{{start: keptEnd, end: lastEnd}}
+	}
+
+	comma := keptEnd + bytes.IndexByte(pt.data[keptEnd:], ',')
+	start := bytes.LastIndexByte(pt.data[:first.keyStart], '\n') + 1
+	for start > comma+1 {
+		prev := bytes.LastIndexByte(pt.data[:start-1], '\n') + 1
+		if prev <= comma || len(bytes.TrimSpace(pt.original[prev:start])) == 0 {
+			break
+		}
+		start = prev
+	}
+	edits := []edit{{start: start, end: lineEnd + 1}}
+	if !bytes.HasPrefix(bytes.TrimLeft(pt.original[lastEnd:lineEnd], " \t"), []byte(",")) {
+		edits = append(edits, edit{start: comma, end: comma + 1})
+	}
+	return edits
+}
+
+// startsLine reports whether only whitespace precedes offset on its line.
+func (pt *patcher) startsLine(offset int) bool {
+	lineStart := bytes.LastIndexByte(pt.data[:offset], '\n') + 1
+	return len(bytes.TrimSpace(pt.original[lineStart:offset])) == 0
+}
+
+// lineIndent returns the leading whitespace of the line containing offset.
+func (pt *patcher) lineIndent(offset int) string {
+	lineStart := bytes.LastIndexByte(pt.data[:offset], '\n') + 1
+	line := pt.original[lineStart:]
+	return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+}
+
+// encode marshals value without escaping HTML, with the given prefix for each
+// new line and indent per level; an empty indent gives compact output.
+func (pt *patcher) encode(value interface{}, prefix, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if indent != "" {
+		encoder.SetIndent(prefix, indent)
+	}
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// detectIndent returns the indentation of the first indented line of data,
+// or two spaces.
+func detectIndent(data []byte) string {
+	lines := bytes.Split(data, []byte("\n"))
+	for _, line := range lines[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 || len(trimmed) == len(line) {
+			continue
+		}
+		return string(line[:len(line)-len(trimmed)])
+	}
+	return "  "
+}
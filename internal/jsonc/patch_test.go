@@ -0,0 +1,155 @@
+package jsonc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	original := `{
+  // Switched by ccc
+  "current_provider": "glm",
+  "providers": {
+    "glm": {
+      "env": { "ANTHROPIC_MODEL": "glm-4.7" } // team gateway
+    },
+    /* expires in March */
+    "kimi": {
+      "env": { "ANTHROPIC_MODEL": "kimi-k2" },
+    },
+  },
+}
+`
+	tests := []struct {
+		name    string
+		updated string
+		want    string
+	}{
+		{
+			name:    "unchanged",
+			updated: `{"current_provider": "glm", "providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}, "kimi": {"env": {"ANTHROPIC_MODEL": "kimi-k2"}}}}`,
+			want:    original,
+		},
+		{
+			name:    "changed value",
+			updated: `{"current_provider": "kimi", "providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}, "kimi": {"env": {"ANTHROPIC_MODEL": "kimi-k2"}}}}`,
+			want:    strings.Replace(original, `"current_provider": "glm"`, `"current_provider": "kimi"`, 1),
+		},
+		{
+			name:    "added members go last",
+			updated: `{"current_provider": "glm", "order": ["kimi", "glm"], "providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}, "kimi": {"env": {"ANTHROPIC_MODEL": "kimi-k2"}}}}`,
+			want:    strings.Replace(original, "    },\n  },\n}", "    },\n  },\n  \"order\": [\n    \"kimi\",\n    \"glm\"\n  ],\n}", 1),
+		},
+		{
+			name:    "added null members are kept",
+			updated: `{"current_provider": "glm", "providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}, "kimi": {"env": {"ANTHROPIC_MODEL": "kimi-k2"}, "model": null}}}`,
+			want:    strings.Replace(original, `"env": { "ANTHROPIC_MODEL": "kimi-k2" },`, "\"env\": { \"ANTHROPIC_MODEL\": \"kimi-k2\" },\n      \"model\": null,", 1),
+		},
+		{
+			name:    "removed first member with its comment",
+			updated: `{"providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}, "kimi": {"env": {"ANTHROPIC_MODEL": "kimi-k2"}}}}`,
+			want:    strings.Replace(original, "  // Switched by ccc\n  \"current_provider\": \"glm\",\n", "", 1),
+		},
+		{
+			name:    "removed last member",
+			updated: `{"current_provider": "glm", "providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}}}}`,
+			want: `{
+  // Switched by ccc
+  "current_provider": "glm",
+  "providers": {
+    "glm": {
+      "env": { "ANTHROPIC_MODEL": "glm-4.7" } // team gateway
+    },
+  },
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Patch([]byte(original), []byte(tt.updated))
+			if err != nil {
+				t.Fatalf("Patch() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Patch() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		updated  string
+		want     string
+	}{
+		{
+			name:     "single-line object stays on one line",
+			original: "{\n  \"env\": {\"A\": \"1\"}\n}",
+			updated:  `{"env": {"A": "1", "B": "a && b"}}`,
+			want:     "{\n  \"env\": {\"A\": \"1\", \"B\": \"a && b\"}\n}",
+		},
+		{
+			name:     "new members follow the indentation of their siblings",
+			original: "{\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}",
+			updated:  `{"a": {"b": 1, "c": {"d": 2}}}`,
+			want:     "{\n\t\"a\": {\n\t\t\"b\": 1,\n\t\t\"c\": {\n\t\t\t\"d\": 2\n\t\t}\n\t}\n}",
+		},
+		{
+			name:     "middle member is deleted with its comment lines",
+			original: "{\n  \"a\": 1,\n  // about b\n  /* more */\n  \"b\": 2, // inline\n  \"c\": 3\n}",
+			updated:  `{"a": 1, "c": 3}`,
+			want:     "{\n  \"a\": 1,\n  \"c\": 3\n}",
+		},
+		{
+			name:     "last member is deleted with its comment, the kept one keeps its own",
+			original: "{\n  \"a\": 1, // about a\n  // about b\n  \"b\": 2 // inline\n}",
+			updated:  `{"a": 1}`,
+			want:     "{\n  \"a\": 1 // about a\n}",
+		},
+		{
+			name:     "members sharing a line",
+			original: `{"a": 1, "b": 2, "c": 3}`,
+			updated:  `{"a": 1, "c": 4}`,
+			want:     `{"a": 1, "c": 4}`,
+		},
+		{
+			name:     "replaced value is indented like its line",
+			original: "{\n  \"order\": [\"a\"]\n}",
+			updated:  `{"order": ["a", "b"]}`,
+			want:     "{\n  \"order\": [\n    \"a\",\n    \"b\"\n  ]\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Patch([]byte(tt.original), []byte(tt.updated))
+			if err != nil {
+				t.Fatalf("Patch() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Patch() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatchRewritesEmptiedObject(t *testing.T) {
+	original := "{\n  \"a\": 1, // one\n  \"b\": 2\n}"
+	got, err := Patch([]byte(original), []byte(`{"c": 3}`))
+	if err != nil {
+		t.Fatalf("Patch() error = %v", err)
+	}
+	if want := "{\n  \"c\": 3\n}"; string(got) != want {
+		t.Errorf("Patch() = %q, want %q", got, want)
+	}
+}
+
+func TestPatchInvalidOriginal(t *testing.T) {
+	if _, err := Patch([]byte("{\"a\": }"), []byte(`{}`)); err == nil {
+		t.Error("Patch() should fail for invalid original")
+	}
+}
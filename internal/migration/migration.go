@@ -1,4 +1,5 @@
-// Package migration handles migrating from old Claude settings to ccc format.
+// Package migration handles migrating from old Claude settings to ccc format,
+// and upgrading ccc.json from older format versions.
 package migration
 
 import (
@@ -89,7 +90,9 @@ func MigrateFromSettings() error {
 	cfg.Providers["default"] = defaultProvider
 
 	// Save the new config
-	if err := config.Save(cfg); err != nil {
+	if err := config.WithSnapshot("migrate from settings.json", func() error {
+		return config.Save(cfg)
+	}); err != nil {
 		return fmt.Errorf("failed to save ccc config: %w", err)
 	}
 
@@ -0,0 +1,215 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// CurrentVersion is the format version of ccc.json written by this ccc.
+// A ccc.json without a version field is at version 0.
+const CurrentVersion = 1
+
+// State is what migrations read and change: the configuration, settings.json
+// and the files to remove. Migrations only change State; Migrate writes it.
+type State struct {
+	Config *config.Config
+	// Settings is the content of settings.json, nil if it doesn't exist.
+	Settings map[string]interface{}
+	// Remove lists the files to remove.
+	Remove []string
+	// Changes describes each change, for `ccc migrate --dry-run`.
+	Changes []string
+}
+
+// Migration upgrades the configuration from the version before it to Version.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(s *State) error
+}
+
+// Migrations are the format migrations, in order. Each one runs once: the
+// version of ccc.json records the last one applied.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "remove the leftovers of supervisor mode (ccc 0.3)",
+		Apply:       removeSupervisor,
+	},
+}
+
+// Pending returns the migrations cfg has not gone through yet, in order.
+func Pending(cfg *config.Config) []Migration {
+	var pending []Migration
+	for _, m := range Migrations {
+		if m.Version > cfg.Version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Plan applies the pending migrations of cfg to a copy of the configuration
+// and settings.json, without writing anything. cfg is left unchanged.
+func Plan(cfg *config.Config) (*State, error) {
+	tree, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	copied, err := config.FromMap(tree)
+	if err != nil {
+		return nil, err
+	}
+	copied.Fragments = cfg.Fragments
+	copied.Secrets = cfg.Secrets
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+	s := &State{Config: copied, Settings: settings}
+	for _, m := range Pending(cfg) {
+		if err := m.Apply(s); err != nil {
+			return nil, fmt.Errorf("migration to version %d failed: %w", m.Version, err)
+		}
+		s.Config.Version = m.Version
+	}
+	return s, nil
+}
+
+// Migrate runs the pending migrations of cfg and writes the result. The
+// files it changes or removes are first copied to a backup directory (see
+// GetBackupDir), whose path is returned; it is empty if no file changed.
+// The caller must hold the config lock.
+func Migrate(cfg *config.Config) (*State, string, error) {
+	s, err := Plan(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.Config.Version == cfg.Version {
+		return s, "", nil
+	}
+
+	settingsChanged := false
+	if s.Settings != nil {
+		current, err := config.LoadSettings()
+		if err != nil {
+			return nil, "", err
+		}
+		settingsChanged = !reflect.DeepEqual(current, s.Settings)
+	}
+
+	var backupDir string
+	if len(s.Changes) > 0 {
+		files := append([]string{config.GetConfigPath()}, s.Remove...)
+		if settingsChanged {
+			files = append(files, config.GetSettingsPath())
+		}
+		backupDir = filepath.Join(GetBackupDir(), fmt.Sprintf("%s-v%d", time.Now().Format("20060102-150405"), cfg.Version))
+		if err := backupFiles(backupDir, files); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := config.WithSnapshot(fmt.Sprintf("migrate to version %d", s.Config.Version), func() error {
+		if settingsChanged {
+			if err := config.SaveSettings(s.Settings); err != nil {
+				return err
+			}
+		}
+		for _, path := range s.Remove {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		}
+		return config.Save(s.Config)
+	}); err != nil {
+		return nil, "", err
+	}
+	return s, backupDir, nil
+}
+
+// GetBackupDir returns the directory holding the backups taken before
+// migrations.
+func GetBackupDir() string {
+	return filepath.Join(config.GetStateDir(), "backups")
+}
+
+// backupFiles copies files into dir, keeping their path relative to the
+// configuration directory. Missing files are skipped.
+func backupFiles(dir string, files []string) error {
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+		rel, err := filepath.Rel(config.GetDir(), path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = filepath.Base(path)
+		}
+		target := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return fmt.Errorf("failed to create backup directory: %w", err)
+		}
+		if err := os.WriteFile(target, data, 0600); err != nil {
+			return fmt.Errorf("failed to back up %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// removeSupervisor removes what supervisor mode, dropped in ccc 0.4, left
+// behind: its Stop hook in settings.json and ccc.json, its slash command
+// files and its state and log files.
+func removeSupervisor(s *State) error {
+	if s.Settings != nil {
+		if cleaned := config.RemoveStopHook(s.Settings); !reflect.DeepEqual(cleaned, s.Settings) {
+			s.Settings = cleaned
+			s.Changes = append(s.Changes, "settings.json: remove the supervisor Stop hook")
+		}
+	}
+	if s.Config.Settings != nil {
+		if cleaned := config.RemoveStopHook(s.Config.Settings); !reflect.DeepEqual(cleaned, s.Config.Settings) {
+			s.Config.Settings = cleaned
+			s.Changes = append(s.Changes, "ccc.json: remove the supervisor Stop hook from settings")
+		}
+	}
+	for _, name := range config.ProviderNames(s.Config) {
+		settings := s.Config.Providers[name]
+		if cleaned := config.RemoveStopHook(settings); !reflect.DeepEqual(cleaned, settings) {
+			s.Config.Providers[name] = cleaned
+			s.Changes = append(s.Changes, fmt.Sprintf("ccc.json: remove the supervisor Stop hook from provider '%s'", name))
+		}
+	}
+
+	commandsDir := filepath.Join(config.GetDir(), "commands")
+	paths := []string{
+		filepath.Join(commandsDir, "supervisor.md"),
+		filepath.Join(commandsDir, "supervisoroff.md"),
+	}
+	stateDir := config.GetStateDir()
+	entries, _ := os.ReadDir(stateDir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "supervisor-") && (strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".log")) {
+			paths = append(paths, filepath.Join(stateDir, name))
+		}
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err == nil {
+			s.Remove = append(s.Remove, path)
+			s.Changes = append(s.Changes, fmt.Sprintf("remove %s", path))
+		}
+	}
+	return nil
+}
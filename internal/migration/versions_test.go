@@ -0,0 +1,136 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// setupSupervisorLeftovers writes the files supervisor mode left behind and
+// a ccc.json at version 0.
+func setupSupervisorLeftovers(t *testing.T) []string {
+	t.Helper()
+
+	stopHook := map[string]interface{}{
+		"Stop": []interface{}{map[string]interface{}{
+			"hooks": []interface{}{map[string]interface{}{"type": "command", "command": "ccc supervisor-hook"}},
+		}},
+	}
+	writeJSONFile(t, config.GetSettingsPath(), map[string]interface{}{"theme": "dark", "hooks": stopHook})
+	ccc := `{
+  // personal providers
+  "providers": {
+    "glm": {"hooks": {"Stop": [{"hooks": [{"type": "command", "command": "ccc supervisor-hook"}]}]}}
+  }
+}`
+	if err := os.WriteFile(config.GetConfigPath(), []byte(ccc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := []string{
+		filepath.Join(config.GetDir(), "commands", "supervisor.md"),
+		filepath.Join(config.GetStateDir(), "supervisor-abc.log"),
+	}
+	for _, path := range artifacts {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("leftover"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return artifacts
+}
+
+func TestPlan(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+	setupSupervisorLeftovers(t)
+	before, _ := os.ReadFile(config.GetConfigPath())
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(Pending(cfg)); got != len(Migrations) {
+		t.Fatalf("Pending() = %d migrations, want all %d", got, len(Migrations))
+	}
+
+	s, err := Plan(cfg)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if s.Config.Version != CurrentVersion || cfg.Version != 0 {
+		t.Errorf("planned version = %d, cfg version = %d, want %d and 0", s.Config.Version, cfg.Version, CurrentVersion)
+	}
+	if len(s.Changes) != 4 {
+		t.Errorf("Changes = %q, want the two hooks and two files", s.Changes)
+	}
+	if _, exists := cfg.Providers["glm"]["hooks"]; !exists {
+		t.Error("Plan() changed cfg")
+	}
+
+	// A dry run writes nothing
+	if after, _ := os.ReadFile(config.GetConfigPath()); string(after) != string(before) {
+		t.Errorf("ccc.json changed by Plan():\n%s", after)
+	}
+	if _, err := os.Stat(GetBackupDir()); !os.IsNotExist(err) {
+		t.Error("Plan() should not take a backup")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+	artifacts := setupSupervisorLeftovers(t)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, backupDir, err := Migrate(cfg)
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if s.Config.Version != CurrentVersion {
+		t.Errorf("version = %d, want %d", s.Config.Version, CurrentVersion)
+	}
+
+	settings := readJSONFile(t, config.GetSettingsPath())
+	compareJSON(t, settings, map[string]interface{}{"theme": "dark"})
+	for _, path := range artifacts {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", path)
+		}
+	}
+
+	data, _ := os.ReadFile(config.GetConfigPath())
+	if !strings.Contains(string(data), "// personal providers") || strings.Contains(string(data), "supervisor") {
+		t.Errorf("ccc.json =\n%s\nwant the hook removed and the comment kept", data)
+	}
+	if !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("ccc.json =\n%s\nwant the version recorded", data)
+	}
+
+	// The originals are backed up
+	for _, rel := range []string{"ccc.json", "settings.json", filepath.Join("commands", "supervisor.md"), filepath.Join("ccc", "supervisor-abc.log")} {
+		if _, err := os.Stat(filepath.Join(backupDir, rel)); err != nil {
+			t.Errorf("backup of %s missing: %v", rel, err)
+		}
+	}
+	if backup, _ := os.ReadFile(filepath.Join(backupDir, "ccc.json")); !strings.Contains(string(backup), "supervisor-hook") {
+		t.Errorf("backed up ccc.json =\n%s\nwant the original", backup)
+	}
+
+	// Migrations run once
+	reloaded, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending := Pending(reloaded); len(pending) != 0 {
+		t.Errorf("Pending() after Migrate() = %v, want none", pending)
+	}
+}
@@ -0,0 +1,246 @@
+package prettyjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// defaultIndent 是 original 中无法识别缩进时使用的缩进（与 Claude Code 一致）
+const defaultIndent = "  "
+
+// MarshalLike 序列化 v，并尽量保留 original 的格式，用于改写用户已有的 JSON 文件
+// - original 中已有的键保持原来的顺序，新增的键按字母顺序追加在后面
+// - 缩进沿用 original 的缩进（识别不到时使用两个空格）
+// - original 以换行结尾时，结果也以换行结尾
+// - 与 Marshal 一样不转义 HTML 字符（例如 hook 命令中的 &&）
+// original 为空或不是合法 JSON 时，仅按字母顺序输出
+func MarshalLike(v interface{}, original []byte) ([]byte, error) {
+	value, err := normalize(v)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原文件无法解析时忽略其顺序
+	order, _ := readOrder(json.NewDecoder(bytes.NewReader(original)))
+
+	var buf bytes.Buffer
+	w := &orderedWriter{buf: &buf, indent: detectIndent(original)}
+	if err := w.write(value, order, 0); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(bytes.TrimRight(original, " \t\r"), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// Equal 判断 data 与 v 序列化后的 JSON 在语义上是否相同（忽略键顺序和格式）
+// data 不是合法 JSON 时返回 false
+func Equal(data []byte, v interface{}) bool {
+	var existing interface{}
+	if err := json.Unmarshal(data, &existing); err != nil {
+		return false
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(existing, value)
+}
+
+// normalize 把任意值转换为 map/slice/json.Number 等通用结构，便于按顺序输出
+// 使用 UseNumber 以保留数字的原始写法
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// orderNode 记录原 JSON 中对象键的顺序，以及嵌套对象/数组元素的顺序
+type orderNode struct {
+	keys   []string
+	fields map[string]*orderNode
+	elems  []*orderNode
+}
+
+// field 返回对象中某个键对应的节点，不存在时返回 nil
+func (n *orderNode) field(key string) *orderNode {
+	if n == nil || n.fields == nil {
+		return nil
+	}
+	return n.fields[key]
+}
+
+// elem 返回数组中第 i 个元素对应的节点，不存在时返回 nil
+func (n *orderNode) elem(i int) *orderNode {
+	if n == nil || i >= len(n.elems) {
+		return nil
+	}
+	return n.elems[i]
+}
+
+// readOrder 读取下一个 JSON 值，返回其中对象键的顺序；标量返回 nil
+func readOrder(decoder *json.Decoder) (*orderNode, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil, nil
+	}
+
+	node := &orderNode{}
+	switch delim {
+	case '{':
+		node.fields = make(map[string]*orderNode)
+		for decoder.More() {
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			child, err := readOrder(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := node.fields[key]; !exists {
+				node.keys = append(node.keys, key)
+			}
+			node.fields[key] = child
+		}
+	case '[':
+		for decoder.More() {
+			child, err := readOrder(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.elems = append(node.elems, child)
+		}
+	}
+
+	// 读取结尾的 } 或 ]
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// detectIndent 返回 data 中第一个缩进行使用的缩进
+func detectIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n"))[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 || len(trimmed) == len(line) {
+			continue
+		}
+		return string(line[:len(line)-len(trimmed)])
+	}
+	return defaultIndent
+}
+
+// orderedWriter 按 orderNode 记录的顺序输出 JSON
+type orderedWriter struct {
+	buf    *bytes.Buffer
+	indent string
+}
+
+func (w *orderedWriter) newline(depth int) {
+	w.buf.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		w.buf.WriteString(w.indent)
+	}
+}
+
+func (w *orderedWriter) write(value interface{}, order *orderNode, depth int) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			w.buf.WriteString("{}")
+			return nil
+		}
+		w.buf.WriteByte('{')
+		for i, key := range orderedKeys(v, order) {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.newline(depth + 1)
+			if err := w.scalar(key); err != nil {
+				return err
+			}
+			w.buf.WriteString(": ")
+			if err := w.write(v[key], order.field(key), depth+1); err != nil {
+				return err
+			}
+		}
+		w.newline(depth)
+		w.buf.WriteByte('}')
+	case []interface{}:
+		if len(v) == 0 {
+			w.buf.WriteString("[]")
+			return nil
+		}
+		w.buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.newline(depth + 1)
+			if err := w.write(elem, order.elem(i), depth+1); err != nil {
+				return err
+			}
+		}
+		w.newline(depth)
+		w.buf.WriteByte(']')
+	default:
+		return w.scalar(v)
+	}
+	return nil
+}
+
+// scalar 输出字符串、数字、布尔值或 null，不转义 HTML 字符
+func (w *orderedWriter) scalar(v interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	w.buf.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	return nil
+}
+
+// orderedKeys 返回对象的键：先是原 JSON 中仍存在的键（保持原顺序），再是按字母排序的新键
+func orderedKeys(m map[string]interface{}, order *orderNode) []string {
+	keys := make([]string, 0, len(m))
+	seen := make(map[string]bool, len(m))
+	if order != nil {
+		for _, key := range order.keys {
+			if _, exists := m[key]; exists {
+				keys = append(keys, key)
+				seen[key] = true
+			}
+		}
+	}
+	var added []string
+	for key := range m {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	return append(keys, added...)
+}
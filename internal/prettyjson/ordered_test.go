@@ -0,0 +1,75 @@
+package prettyjson
+
+import (
+	"testing"
+)
+
+func TestMarshalLike(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		original string
+		expected string
+	}{
+		{
+			name:     "no original uses sorted keys and two spaces",
+			input:    map[string]interface{}{"b": 1, "a": "x"},
+			original: "",
+			expected: "{\n  \"a\": \"x\",\n  \"b\": 1\n}",
+		},
+		{
+			name:     "keeps existing key order and appends new keys sorted",
+			input:    map[string]interface{}{"a": 1, "c": 3, "z": 26, "d": 4},
+			original: "{\"z\": 0, \"a\": 0}",
+			expected: "{\n  \"z\": 26,\n  \"a\": 1,\n  \"c\": 3,\n  \"d\": 4\n}",
+		},
+		{
+			name: "keeps nested order, indentation and trailing newline",
+			input: map[string]interface{}{
+				"hooks": []interface{}{
+					map[string]interface{}{"type": "command", "command": "a && b"},
+				},
+				"env": map[string]interface{}{"Y": "1", "X": "2"},
+			},
+			original: "{\n\t\"env\": {\"Y\": \"0\", \"X\": \"0\"},\n\t\"hooks\": [{\"type\": \"\", \"command\": \"\"}]\n}\n",
+			expected: "{\n\t\"env\": {\n\t\t\"Y\": \"1\",\n\t\t\"X\": \"2\"\n\t},\n\t\"hooks\": [\n\t\t{\n\t\t\t\"type\": \"command\",\n\t\t\t\"command\": \"a && b\"\n\t\t}\n\t]\n}\n",
+		},
+		{
+			name:     "invalid original is ignored",
+			input:    map[string]interface{}{"b": []interface{}{}, "a": map[string]interface{}{}},
+			original: "{not json",
+			expected: "{\n  \"a\": {},\n  \"b\": []\n}",
+		},
+		{
+			name:     "numbers keep their form",
+			input:    map[string]interface{}{"n": 1e21, "m": 3.5, "k": nil},
+			original: "",
+			expected: "{\n  \"k\": null,\n  \"m\": 3.5,\n  \"n\": 1e+21\n}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MarshalLike(tt.input, []byte(tt.original))
+			if err != nil {
+				t.Fatalf("MarshalLike() error = %v", err)
+			}
+			if string(result) != tt.expected {
+				t.Errorf("MarshalLike() = %q, want %q", string(result), tt.expected)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	value := map[string]interface{}{"a": 1, "b": []interface{}{"x", true}}
+	if !Equal([]byte("{\"b\": [\"x\", true], \"a\": 1.0}"), value) {
+		t.Error("Equal() should ignore key order and number formatting")
+	}
+	if Equal([]byte("{\"a\": 1}"), value) {
+		t.Error("Equal() should detect a missing key")
+	}
+	if Equal([]byte("{not json"), value) {
+		t.Error("Equal() should be false for invalid JSON")
+	}
+}
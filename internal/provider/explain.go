@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// Source is a layer a value of the launch settings comes from.
+type Source struct {
+	// Layer is "settings" (the base settings of ccc.json), "provider",
+	// "project" or "settings.json".
+	Layer string `json:"layer"`
+	// Provider is the provider of a "provider" layer: the launched provider
+	// or one it extends.
+	Provider string `json:"provider,omitempty"`
+	// File is the file that sets the value.
+	File string `json:"file,omitempty"`
+}
+
+// String describes the source, e.g. "provider glm (ccc.d/10-team.json)".
+// Files in the configuration directory are shown relative to it.
+func (s Source) String() string {
+	name := s.Layer
+	if s.Provider != "" {
+		name += " " + s.Provider
+	}
+	file := s.File
+	if rel, err := filepath.Rel(config.GetDir(), file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	if file == "" || file == name {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, file)
+}
+
+// ExplainedValue is a value a provider is launched with, and the layers it
+// comes from.
+type ExplainedValue struct {
+	Path    string      `json:"path"`
+	Value   interface{} `json:"value"`
+	Sources []Source    `json:"sources"`
+}
+
+// explainLayer is a layer of the launch settings, lowest first.
+type explainLayer struct {
+	source Source
+	data   map[string]interface{}
+	// configPath is where data sits in the configuration files, nil for
+	// layers outside of them.
+	configPath []string
+}
+
+// Explain returns every value a provider is launched with by SwitchWithHook:
+// the settings written to settings.json, and under "env" the variables of
+// the claude process and of settings.json. Values are sorted by path; each
+// lists the layers it comes from, lowest first. Arrays merged with a strategy
+// list every layer that contributed. Nothing is written.
+func Explain(cfg *config.Config, providerName string) ([]ExplainedValue, error) {
+	providerSettings, err := ResolveSettings(cfg, providerName)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := config.ProviderChain(cfg, providerName)
+	if err != nil {
+		return nil, err
+	}
+	userSettings, err := loadUserSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	files, err := config.SourceFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := mergeSettings(cfg, providerSettings, userSettings)
+	if env := config.DeepMerge(LaunchEnv(cfg, providerSettings), config.GetEnv(effective)); len(env) > 0 {
+		effective["env"] = env
+	}
+
+	// Overlays are normalized like MergePatch applies them: literals are
+	// unwrapped and deletions dropped
+	layers := []explainLayer{{source: Source{Layer: "settings"}, data: cfg.Settings, configPath: []string{"settings"}}}
+	for _, name := range chain {
+		layers = append(layers, explainLayer{
+			source:     Source{Layer: "provider", Provider: name},
+			data:       config.MergePatch(nil, cfg.Providers[name], nil),
+			configPath: []string{"providers", name},
+		})
+	}
+	if cfg.Project != nil {
+		layers = append(layers, explainLayer{
+			source: Source{Layer: "project", File: cfg.Project.Path},
+			data:   config.MergePatch(nil, cfg.Project.Settings, nil),
+		})
+	}
+	layers = append(layers, explainLayer{
+		source: Source{Layer: "settings.json", File: config.GetSettingsPath()},
+		data:   userSettings,
+	})
+
+	var values []ExplainedValue
+	collectValues(effective, nil, func(path []string, value interface{}) {
+		values = append(values, ExplainedValue{
+			Path:    strings.Join(path, "."),
+			Value:   value,
+			Sources: valueSources(layers, files, path, value),
+		})
+	})
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Path < values[j].Path
+	})
+	return values, nil
+}
+
+// collectValues calls visit for every leaf under value. Arrays and empty
+// objects are leaves.
+func collectValues(value interface{}, path []string, visit func([]string, interface{})) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for key, child := range m {
+			collectValues(child, append(append([]string{}, path...), key), visit)
+		}
+		return
+	}
+	visit(path, value)
+}
+
+// valueSources returns the layers the value at path comes from: the highest
+// layer that sets it as is, or for a merged array, every layer with an array
+// there.
+func valueSources(layers []explainLayer, files []*config.Fragment, path []string, value interface{}) []Source {
+	var sources []Source
+	_, isArray := value.([]interface{})
+	for i := len(layers) - 1; i >= 0; i-- {
+		layerValue, ok := config.GetPath(layers[i].data, path)
+		if !ok {
+			continue
+		}
+		_, layerArray := layerValue.([]interface{})
+		if equal := reflect.DeepEqual(layerValue, value); equal || (isArray && layerArray) {
+			source := layers[i].source
+			if layers[i].configPath != nil {
+				source.File = sourceFile(files, append(append([]string{}, layers[i].configPath...), path...), len(layers[i].configPath))
+			}
+			sources = append([]Source{source}, sources...)
+			if equal {
+				break
+			}
+		}
+	}
+	return sources
+}
+
+// sourceFile returns the last of files that sets path, or the closest of its
+// parents below the first min keys (a value wrapped in $literal is set by
+// its parent).
+func sourceFile(files []*config.Fragment, path []string, min int) string {
+	for n := len(path); n > min; n-- {
+		for i := len(files) - 1; i >= 0; i-- {
+			if _, ok := config.GetPath(files[i].Data, path[:n]); ok {
+				return files[i].Path
+			}
+		}
+	}
+	return ""
+}
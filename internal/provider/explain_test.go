@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestExplain(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(config.GetFragmentDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	team := `{"providers": {"glm": {"env": {"ANTHROPIC_MODEL": "glm-4.7"}, "permissions": {"allow": ["Bash"]}}}}`
+	if err := os.WriteFile(filepath.Join(config.GetFragmentDir(), "10-team.json"), []byte(team), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ccc := `{
+  "settings": {"alwaysThinkingEnabled": true, "statusLine": {"type": "command"}, "permissions": {"allow": ["Read"]}},
+  "merge": {"permissions.allow": "union"},
+  "providers": {
+    "glm": {"env": {"ANTHROPIC_AUTH_TOKEN": "sk-glm"}},
+    "glm-air": {"extends": "glm", "alwaysThinkingEnabled": null, "env": {"ANTHROPIC_MODEL": "glm-4.5-air"}}
+  }
+}`
+	if err := os.WriteFile(config.GetConfigPath(), []byte(ccc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveSettings(map[string]interface{}{
+		"theme":       "dark",
+		"permissions": map[string]interface{}{"allow": []interface{}{"Edit"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Project = &config.ProjectConfig{
+		Path:     "/work/.ccc.json",
+		Settings: map[string]interface{}{"statusLine": map[string]interface{}{"$literal": nil}},
+	}
+
+	values, err := Explain(cfg, "glm-air")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	configPath, teamPath := config.GetConfigPath(), filepath.Join(config.GetFragmentDir(), "10-team.json")
+	want := []ExplainedValue{
+		{Path: "env.ANTHROPIC_AUTH_TOKEN", Value: "sk-glm", Sources: []Source{{Layer: "provider", Provider: "glm", File: configPath}}},
+		{Path: "env.ANTHROPIC_MODEL", Value: "glm-4.5-air", Sources: []Source{{Layer: "provider", Provider: "glm-air", File: configPath}}},
+		{Path: "permissions.allow", Value: []interface{}{"Read", "Bash", "Edit"}, Sources: []Source{
+			{Layer: "settings", File: configPath},
+			{Layer: "provider", Provider: "glm", File: teamPath},
+			{Layer: "settings.json", File: config.GetSettingsPath()},
+		}},
+		{Path: "statusLine", Value: nil, Sources: []Source{{Layer: "project", File: "/work/.ccc.json"}}},
+		{Path: "theme", Value: "dark", Sources: []Source{{Layer: "settings.json", File: config.GetSettingsPath()}}},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Explain() =\n%+v\nwant\n%+v", values, want)
+	}
+
+	// Nothing is written
+	if _, err := os.Stat(config.GetManagedPath()); !os.IsNotExist(err) {
+		t.Error("Explain() should not write the managed record")
+	}
+}
+
+func TestSourceString(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		source Source
+		want   string
+	}{
+		{Source{Layer: "settings", File: config.GetConfigPath()}, "settings (ccc.json)"},
+		{Source{Layer: "provider", Provider: "glm", File: filepath.Join(config.GetFragmentDir(), "10-team.json")}, "provider glm (ccc.d/10-team.json)"},
+		{Source{Layer: "settings.json", File: config.GetSettingsPath()}, "settings.json"},
+		{Source{Layer: "project", File: "/work/.ccc.json"}, "project (/work/.ccc.json)"},
+	}
+	for _, tt := range tests {
+		if got := tt.source.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
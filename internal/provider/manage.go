@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// ValidateName checks that name can be used as a new provider name.
+// Names must be non-empty, must not start with "-" (they would be taken as
+// claude flags) and must not contain whitespace, "/" or "." (dots separate
+// path segments in `ccc cfg`).
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("provider name must not be empty")
+	}
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("provider name '%s' must not start with '-'", name)
+	}
+	if strings.ContainsAny(name, " \t\n/.") {
+		return fmt.Errorf("provider name '%s' must not contain whitespace, '/' or '.'", name)
+	}
+	return nil
+}
+
+// Add adds a new provider with the given settings.
+// Returns an error if a provider with the same name already exists.
+func Add(cfg *config.Config, name string, settings map[string]interface{}) error {
+	if cfg == nil {
+		return fmt.Errorf("config is nil")
+	}
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if _, exists := cfg.Providers[name]; exists {
+		return fmt.Errorf("provider '%s' already exists", name)
+	}
+
+	if cfg.Providers == nil {
+		cfg.Providers = make(map[string]map[string]interface{})
+	}
+	if settings == nil {
+		settings = make(map[string]interface{})
+	}
+	cfg.Providers[name] = settings
+	return nil
+}
+
+// Remove deletes a provider. If it was the current or default provider,
+// current_provider or default_provider is cleared, and it is dropped from order.
+// Providers that other providers extend cannot be removed.
+func Remove(cfg *config.Config, name string) error {
+	if err := ValidateProvider(cfg, name); err != nil {
+		return err
+	}
+	if children := config.ProvidersExtending(cfg, name); len(children) > 0 {
+		return fmt.Errorf("provider '%s' is extended by: %s", name, strings.Join(children, ", "))
+	}
+
+	delete(cfg.Providers, name)
+	if cfg.CurrentProvider == name {
+		cfg.CurrentProvider = ""
+	}
+	if cfg.DefaultProvider == name {
+		cfg.DefaultProvider = ""
+	}
+	order := cfg.Order[:0]
+	for _, n := range cfg.Order {
+		if n != name {
+			order = append(order, n)
+		}
+	}
+	cfg.Order = order
+	return nil
+}
+
+// Rename renames a provider, keeping current_provider, default_provider, order
+// and the extends keys of other providers pointing at it.
+func Rename(cfg *config.Config, oldName, newName string) error {
+	if err := ValidateProvider(cfg, oldName); err != nil {
+		return err
+	}
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+	if _, exists := cfg.Providers[newName]; exists {
+		return fmt.Errorf("provider '%s' already exists", newName)
+	}
+
+	for _, child := range config.ProvidersExtending(cfg, oldName) {
+		cfg.Providers[child][config.ExtendsKey] = newName
+	}
+	cfg.Providers[newName] = cfg.Providers[oldName]
+	delete(cfg.Providers, oldName)
+	if cfg.CurrentProvider == oldName {
+		cfg.CurrentProvider = newName
+	}
+	if cfg.DefaultProvider == oldName {
+		cfg.DefaultProvider = newName
+	}
+	for i, n := range cfg.Order {
+		if n == oldName {
+			cfg.Order[i] = newName
+		}
+	}
+	return nil
+}
+
+// Copy creates a new provider as a deep copy of an existing one.
+func Copy(cfg *config.Config, srcName, dstName string) error {
+	if err := ValidateProvider(cfg, srcName); err != nil {
+		return err
+	}
+	if err := ValidateName(dstName); err != nil {
+		return err
+	}
+	if _, exists := cfg.Providers[dstName]; exists {
+		return fmt.Errorf("provider '%s' already exists", dstName)
+	}
+
+	copied := config.DeepCopy(cfg.Providers[srcName])
+	if copied == nil {
+		copied = make(map[string]interface{})
+	}
+	cfg.Providers[dstName] = copied
+	return nil
+}
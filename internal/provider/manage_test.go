@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+func TestValidateName(t *testing.T) {
+	valid := []string{"glm", "kimi-k2", "glm_fast", "88code"}
+	for _, name := range valid {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) error = %v, want nil", name, err)
+		}
+	}
+
+	invalid := []string{"", "-glm", "glm fast", "glm/fast", "glm.fast"}
+	for _, name := range invalid {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) = nil, want error", name)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	cfg := setupTestConfig(t)
+
+	settings := map[string]interface{}{"env": map[string]interface{}{"ANTHROPIC_MODEL": "m"}}
+	if err := Add(cfg, "minimax", settings); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, exists := cfg.Providers["minimax"]; !exists {
+		t.Error("Add() should add the provider")
+	}
+
+	err := Add(cfg, "glm", nil)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Add() existing provider error = %v, want 'already exists'", err)
+	}
+
+	empty := &config.Config{}
+	if err := Add(empty, "glm", nil); err != nil {
+		t.Fatalf("Add() to empty config error = %v", err)
+	}
+	if empty.Providers["glm"] == nil {
+		t.Error("Add() should initialize providers map and settings")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	cfg := setupTestConfig(t)
+
+	if err := Remove(cfg, "glm"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, exists := cfg.Providers["glm"]; exists {
+		t.Error("Remove() should delete the provider")
+	}
+	if cfg.CurrentProvider != "kimi" {
+		t.Errorf("CurrentProvider = %q, want kimi (unchanged)", cfg.CurrentProvider)
+	}
+
+	if err := Remove(cfg, "kimi"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if cfg.CurrentProvider != "" {
+		t.Errorf("CurrentProvider = %q, want empty after removing current", cfg.CurrentProvider)
+	}
+
+	if err := Remove(cfg, "unknown"); err == nil {
+		t.Error("Remove() should error for unknown provider")
+	}
+
+	cfg = setupTestConfig(t)
+	cfg.DefaultProvider = "glm"
+	cfg.Order = []string{"kimi", "glm"}
+	if err := Remove(cfg, "glm"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if cfg.DefaultProvider != "" || len(cfg.Order) != 1 || cfg.Order[0] != "kimi" {
+		t.Errorf("default_provider = %q, order = %v, want removed provider dropped", cfg.DefaultProvider, cfg.Order)
+	}
+
+	cfg = setupTestConfig(t)
+	cfg.Providers["glm-air"] = map[string]interface{}{"extends": "glm"}
+	err := Remove(cfg, "glm")
+	if err == nil || !strings.Contains(err.Error(), "extended by: glm-air") {
+		t.Errorf("Remove() of an extended provider error = %v, want 'extended by: glm-air'", err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	cfg := setupTestConfig(t)
+
+	if err := Rename(cfg, "kimi", "moonshot"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if _, exists := cfg.Providers["kimi"]; exists {
+		t.Error("Rename() should remove the old name")
+	}
+	if config.GetModel(cfg.Providers["moonshot"]) != "kimi-k2-thinking" {
+		t.Error("Rename() should keep the provider settings")
+	}
+	if cfg.CurrentProvider != "moonshot" {
+		t.Errorf("CurrentProvider = %q, want moonshot", cfg.CurrentProvider)
+	}
+
+	if err := Rename(cfg, "moonshot", "glm"); err == nil {
+		t.Error("Rename() onto an existing provider should error")
+	}
+	if err := Rename(cfg, "unknown", "x"); err == nil {
+		t.Error("Rename() of unknown provider should error")
+	}
+
+	cfg.Providers["glm-air"] = map[string]interface{}{"extends": "glm"}
+	cfg.DefaultProvider = "glm"
+	cfg.Order = []string{"glm", "moonshot"}
+	if err := Rename(cfg, "glm", "zhipu"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if cfg.DefaultProvider != "zhipu" || cfg.Order[0] != "zhipu" {
+		t.Errorf("default_provider = %q, order = %v, want renamed", cfg.DefaultProvider, cfg.Order)
+	}
+	if cfg.Providers["glm-air"]["extends"] != "zhipu" {
+		t.Errorf("extends = %v, want zhipu", cfg.Providers["glm-air"]["extends"])
+	}
+}
+
+func TestCopy(t *testing.T) {
+	cfg := setupTestConfig(t)
+
+	if err := Copy(cfg, "glm", "glm-air"); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	// The copy must be independent of the source
+	config.GetEnv(cfg.Providers["glm-air"])["ANTHROPIC_MODEL"] = "glm-4.5-air"
+	if config.GetModel(cfg.Providers["glm"]) != "glm-4.7" {
+		t.Error("Copy() should deep copy the provider settings")
+	}
+	if cfg.CurrentProvider != "kimi" {
+		t.Errorf("CurrentProvider = %q, want kimi (unchanged)", cfg.CurrentProvider)
+	}
+
+	if err := Copy(cfg, "glm", "kimi"); err == nil {
+		t.Error("Copy() onto an existing provider should error")
+	}
+}
@@ -3,9 +3,13 @@ package provider
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 
 	"github.com/guyskk/ccc/internal/config"
 )
@@ -24,126 +28,360 @@ type SwitchResult struct {
 	// EnvVars contains the merged environment variables (settings.env + provider.env)
 	// that should be passed to the claude subprocess
 	EnvVars []EnvPair
+	// SettingsPath is the per-session settings file to pass to claude via --settings.
+	// Empty unless the result comes from PrepareSession.
+	SettingsPath string
 }
 
-// SwitchWithHook switches to the specified provider and cleans up supervisor hooks.
+// SwitchWithHook switches to the specified provider.
 // It generates settings.json with merged configuration from:
 //  1. Existing settings.json (user config - highest priority)
 //  2. ccc.json settings (base template)
 //  3. Provider settings (provider-specific)
 //
-// It also removes any leftover supervisor artifacts (slash commands, state, logs).
+// Values ccc wrote into settings.json on the previous switch are recorded in the
+// managed record and removed before merging, so they are replaced by the new
+// provider's values instead of being treated as user config.
+//
+// The whole cycle runs under the config lock. If the switch changes any file,
+// the files from before it are kept as a snapshot for `ccc undo`. The write is
+// all-or-nothing: if any step fails, every file it changed is restored to its
+// previous content.
 // Returns the merged env that should be passed to the claude subprocess.
 func SwitchWithHook(cfg *config.Config, providerName string) (*SwitchResult, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config is nil")
 	}
 
-	// Check if provider exists
-	providerSettings, exists := cfg.Providers[providerName]
-	if !exists {
-		return nil, fmt.Errorf("provider '%s' not found in configuration", providerName)
+	// Resolve the provider, including inherited and project settings
+	providerSettings, err := ResolveSettings(cfg, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the subprocess env first, so a bad reference fails before any file is written
+	envVars, err := subprocessEnv(cfg, providerName, providerSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hold the config lock for the whole read-merge-write cycle, so concurrent
+	// launches can't interleave and lose each other's writes
+	unlock, err := config.Lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	userSettings, err := loadUserSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mergedSettings := mergeSettings(cfg, providerSettings, userSettings)
+
+	// Keep a copy of the files from before the switch in the history, for
+	// `ccc undo`; a switch that changes nothing, or is rolled back, leaves none
+	if err := config.WithSnapshot(fmt.Sprintf("switch to %s", providerName), func() error {
+		return writeSwitch(cfg, providerName, providerSettings, userSettings, mergedSettings)
+	}); err != nil {
+		return nil, err
+	}
+
+	return &SwitchResult{
+		Settings: mergedSettings,
+		EnvVars:  envVars,
+	}, nil
+}
+
+// writeSwitch writes the files of a switch to providerName: settings.json,
+// the managed record and current_provider in ccc.json. The files are backed up
+// first, so a failure at any step restores the previous state instead of a
+// half-applied switch.
+func writeSwitch(cfg *config.Config, providerName string, providerSettings, userSettings, mergedSettings map[string]interface{}) error {
+	tx := &switchTxn{}
+	for _, path := range []string{config.GetSettingsPath(), config.GetManagedPath(), config.GetConfigPath(), config.GetSecretsPath()} {
+		if err := tx.backup(path); err != nil {
+			return err
+		}
+	}
+	fail := func(err error) error {
+		if rollbackErr := tx.rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	// Save merged settings to settings.json
+	if err := saveSettings(mergedSettings); err != nil {
+		return fail(err)
 	}
 
-	// Load existing settings.json (user's actual configuration)
+	// Record which values ccc wrote, so the next switch can replace them
+	cccSettings := config.MergeWithPriority(cfg.Settings, providerSettings, nil, cfg.Merge)
+	delete(cccSettings, "env")
+	if err := saveManaged(&config.ManagedRecord{
+		Provider: providerName,
+		Entries:  config.CollectManaged(cccSettings, userSettings, cfg.Merge),
+	}); err != nil {
+		return fail(fmt.Errorf("failed to record managed settings: %w", err))
+	}
+
+	// Update current_provider in ccc.json
+	if err := saveCurrentProvider(cfg, providerName); err != nil {
+		return fail(fmt.Errorf("failed to update current provider: %w", err))
+	}
+	return nil
+}
+
+// saveCurrentProvider sets current_provider in ccc.json. cfg may have been
+// loaded before the lock was taken, so ccc.json is read again and only
+// current_provider changes: edits made in the meantime, e.g. by another ccc
+// process, are kept.
+func saveCurrentProvider(cfg *config.Config, providerName string) error {
+	current, err := config.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		current = cfg
+	} else if err != nil {
+		return err
+	}
+	current.CurrentProvider = providerName
+	if err := saveConfig(current); err != nil {
+		return err
+	}
+	cfg.CurrentProvider = providerName
+	return nil
+}
+
+// loadUserSettings loads settings.json without the values ccc wrote on the
+// previous switch, so only the user's own edits remain in the "user" layer.
+func loadUserSettings(cfg *config.Config) (map[string]interface{}, error) {
 	userSettings, err := config.LoadSettings()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load settings: %w", err)
 	}
+	record, err := config.LoadManaged()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load managed record: %w", err)
+	}
+	if record == nil && userSettings != nil {
+		record = bootstrapManaged(cfg)
+	}
+	return config.StripManaged(userSettings, record), nil
+}
 
-	// Extract env from each source before merging (to distinguish user env from ccc env)
-	userEnvMap := config.GetEnv(userSettings)
-	baseEnvMap := config.GetEnv(cfg.Settings)
-	providerEnvMap := config.GetEnv(providerSettings)
+// bootstrapManaged returns the managed record of a settings.json written by a
+// ccc that didn't keep one yet: what the current provider contributes to it.
+// Returns nil if there is no current provider or it can't be resolved; the
+// next switch then writes the record.
+func bootstrapManaged(cfg *config.Config) *config.ManagedRecord {
+	if _, exists := cfg.Providers[cfg.CurrentProvider]; !exists {
+		return nil
+	}
+	providerSettings, err := config.ResolveProvider(cfg, cfg.CurrentProvider)
+	if err != nil {
+		return nil
+	}
+	cccSettings := config.MergeWithPriority(cfg.Settings, providerSettings, nil, cfg.Merge)
+	delete(cccSettings, "env")
+	return config.BootstrapManaged(cfg.CurrentProvider, cccSettings, cfg.Merge)
+}
 
-	// Build managed keys = base env keys + provider env keys
+// mergeSettings returns the settings.json written for a provider: the user's
+// settings over the provider's over the base settings. Its env only keeps the
+// user's own variables; the base and provider env go to the claude process.
+func mergeSettings(cfg *config.Config, providerSettings, userSettings map[string]interface{}) map[string]interface{} {
+	// Managed env keys = base env keys + provider env keys
 	managedEnvKeys := make(map[string]bool)
-	for key := range baseEnvMap {
+	for key := range config.GetEnv(cfg.Settings) {
 		managedEnvKeys[key] = true
 	}
-	for key := range providerEnvMap {
+	for key := range config.GetEnv(providerSettings) {
 		managedEnvKeys[key] = true
 	}
 
 	// Merge settings with priority: user > provider > base
-	mergedSettings := config.MergeWithPriority(cfg.Settings, providerSettings, userSettings)
-
-	// Remove Supervisor Stop hook and related fields
-	cleanedSettings := config.RemoveStopHook(mergedSettings)
+	merged := config.MergeWithPriority(cfg.Settings, providerSettings, userSettings, cfg.Merge)
 
 	// Remove merged env from settings, replace with filtered user env
-	delete(cleanedSettings, "env")
-	if filtered := config.FilterUserEnvForSettings(userEnvMap, managedEnvKeys); len(filtered) > 0 {
-		cleanedSettings["env"] = filtered
+	delete(merged, "env")
+	if filtered := config.FilterUserEnvForSettings(config.GetEnv(userSettings), managedEnvKeys); len(filtered) > 0 {
+		merged["env"] = filtered
 	}
+	return merged
+}
 
-	// Save merged settings to settings.json
-	settingsPath := config.GetSettingsPath()
-	settingsData, err := json.MarshalIndent(cleanedSettings, "", "  ")
+// PrepareSession prepares a provider launch that leaves the shared settings.json
+// and ccc.json untouched. The merged base + provider settings (without env) are
+// written to a private per-session file that claude loads through its --settings
+// flag, so several sessions with different providers can run side by side.
+// Claude still reads settings.json, so the values the last switch wrote there
+// are overridden in the session file too (see overrideManaged).
+//
+// The caller must remove result.SettingsPath once claude exits.
+func PrepareSession(cfg *config.Config, providerName string) (*SwitchResult, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+
+	providerSettings, err := ResolveSettings(cfg, providerName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+		return nil, err
 	}
-	if err := os.WriteFile(settingsPath, settingsData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write settings: %w", err)
+
+	envVars, err := subprocessEnv(cfg, providerName, providerSettings)
+	if err != nil {
+		return nil, err
 	}
 
-	// Clean up any leftover supervisor artifacts
-	cleanupSupervisorArtifacts()
+	sessionSettings := config.MergeWithPriority(cfg.Settings, providerSettings, nil, cfg.Merge)
+	delete(sessionSettings, "env")
+	if err := overrideManaged(cfg, sessionSettings); err != nil {
+		return nil, err
+	}
 
-	// Update current_provider in ccc.json
-	cfg.CurrentProvider = providerName
-	if err := config.Save(cfg); err != nil {
-		return nil, fmt.Errorf("failed to update current provider: %w", err)
+	sessionDir := config.GetSessionDir()
+	if err := os.MkdirAll(sessionDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
 	}
+	cleanupStaleSessions(sessionDir)
 
-	// Extract env map for subprocess: only base + provider env (not user env)
-	subprocessEnvMap := config.MergeEnvMaps(baseEnvMap, providerEnvMap)
+	data, err := json.MarshalIndent(sessionSettings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal session settings: %w", err)
+	}
 
-	// Convert env map to EnvPair slice
-	envVars := envMapToPairs(subprocessEnvMap)
+	// CreateTemp creates the file with mode 0600, keeping it private to the user
+	f, err := os.CreateTemp(sessionDir, fmt.Sprintf("settings-%d-*.json", os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session settings: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to write session settings: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("failed to write session settings: %w", err)
+	}
 
 	return &SwitchResult{
-		Settings: cleanedSettings,
-		EnvVars:  envVars,
+		Settings:     sessionSettings,
+		EnvVars:      envVars,
+		SettingsPath: f.Name(),
 	}, nil
 }
 
-// cleanupSupervisorArtifacts removes leftover supervisor files:
-//   - slash command files (supervisor.md, supervisoroff.md)
-//   - state files (supervisor-*.json) and log files (supervisor-*.log)
-func cleanupSupervisorArtifacts() {
-	commandsDir := config.GetDir() + "/commands"
-	os.Remove(commandsDir + "/supervisor.md")
-	os.Remove(commandsDir + "/supervisoroff.md")
+// overrideManaged adds to sessionSettings every value of the managed record
+// (the values the last switch wrote into settings.json) that the session
+// doesn't set itself, so the global provider doesn't leak into the session.
+// Each gets the user's own value at its path, or null if there is none.
+func overrideManaged(cfg *config.Config, sessionSettings map[string]interface{}) error {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	if settings == nil {
+		return nil
+	}
+	record, err := config.LoadManaged()
+	if err != nil {
+		return fmt.Errorf("failed to load managed record: %w", err)
+	}
+	if record == nil {
+		record = bootstrapManaged(cfg)
+	}
+	if record == nil {
+		return nil
+	}
 
-	stateDir := config.GetDir() + "/ccc"
-	entries, err := os.ReadDir(stateDir)
+	userSettings := config.StripManaged(settings, record)
+	for _, entry := range record.Entries {
+		if _, exists := config.GetPath(sessionSettings, entry.Path); exists {
+			continue
+		}
+		value, _ := config.GetPath(userSettings, entry.Path)
+		// A path under a non-object value of the session can't be set;
+		// the session's value already replaces it
+		_ = config.SetPath(sessionSettings, entry.Path, value)
+	}
+	return nil
+}
+
+// ResolveSettings returns the settings a provider is launched with: the
+// provider resolved through its extends chain, with the settings of the
+// project file (if any) merged on top, using the array merge strategies of
+// ccc.json. Nulls are kept, so MergeWithPriority removes those keys from the
+// base settings as well.
+func ResolveSettings(cfg *config.Config, providerName string) (map[string]interface{}, error) {
+	settings, err := config.ResolveProvider(cfg, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Project != nil && len(cfg.Project.Settings) > 0 {
+		settings = config.DeepMergeWith(settings, cfg.Project.Settings, cfg.Merge)
+	}
+	return settings, nil
+}
+
+// cleanupStaleSessions removes session settings files left behind by ccc
+// processes that no longer exist (e.g. killed before they could clean up).
+func cleanupStaleSessions(sessionDir string) {
+	entries, err := os.ReadDir(sessionDir)
 	if err != nil {
 		return
 	}
 	for _, entry := range entries {
-		name := entry.Name()
-		if strings.HasPrefix(name, "supervisor-") && (strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".log")) {
-			os.Remove(stateDir + "/" + name)
+		var pid int
+		if _, err := fmt.Sscanf(entry.Name(), "settings-%d-", &pid); err != nil || pid <= 0 {
+			continue
+		}
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			os.Remove(filepath.Join(sessionDir, entry.Name()))
 		}
 	}
 }
 
-// envMapToPairs converts a map[string]interface{} to []EnvPair.
-// It expands environment variable references like ${VAR}.
-func envMapToPairs(envMap map[string]interface{}) []EnvPair {
+// LaunchEnv returns the env of the claude process for providerSettings (as
+// returned by ResolveSettings) before references are resolved: the base env
+// with the provider's on top. The user env of settings.json is not included.
+func LaunchEnv(cfg *config.Config, providerSettings map[string]interface{}) map[string]interface{} {
+	// A null in the provider's env unsets a variable of the base env
+	return config.MergePatch(config.GetEnv(cfg.Settings), config.GetEnv(providerSettings), nil)
+}
+
+// subprocessEnv returns the env to pass to the claude subprocess:
+// only base + provider env (not user env), with references resolved.
+func subprocessEnv(cfg *config.Config, providerName string, providerSettings map[string]interface{}) ([]EnvPair, error) {
+	pairs, err := envMapToPairs(LaunchEnv(cfg, providerSettings))
+	if err != nil {
+		return nil, fmt.Errorf("provider '%s': %w", providerName, err)
+	}
+	return pairs, nil
+}
+
+// envMapToPairs converts a map[string]interface{} to []EnvPair, sorted by key.
+// It expands environment variable references like ${VAR} and resolves secret
+// references (file:, env:, cmd:) and encrypted values. Returns a *config.EnvError listing every
+// value that could not be resolved.
+func envMapToPairs(envMap map[string]interface{}) ([]EnvPair, error) {
 	if envMap == nil {
-		return nil
+		return nil, nil
+	}
+
+	resolved, err := config.ResolveEnv(envMap)
+	if err != nil {
+		return nil, err
 	}
 
-	pairs := make([]EnvPair, 0, len(envMap))
-	for k, v := range envMap {
-		value := fmt.Sprintf("%v", v)
-		// Expand environment variable references
-		value = os.ExpandEnv(value)
-		pairs = append(pairs, EnvPair{Key: k, Value: value})
+	pairs := make([]EnvPair, 0, len(resolved))
+	for k, v := range resolved {
+		pairs = append(pairs, EnvPair{Key: k, Value: v})
 	}
-	return pairs
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Key < pairs[j].Key
+	})
+	return pairs, nil
 }
 
 // EnvPairsToStrings converts []EnvPair to []string in "KEY=value" format.
@@ -168,17 +406,10 @@ func FormatProviderName(name, currentProvider string) string {
 	return name
 }
 
-// ListProviders returns a list of all provider names from the config.
+// ListProviders returns all provider names from the config, in the order
+// given by the order field followed by the remaining names sorted.
 func ListProviders(cfg *config.Config) []string {
-	if cfg == nil || len(cfg.Providers) == 0 {
-		return []string{}
-	}
-
-	names := make([]string, 0, len(cfg.Providers))
-	for name := range cfg.Providers {
-		names = append(names, name)
-	}
-	return names
+	return config.ProviderNames(cfg)
 }
 
 // ValidateProvider checks if a provider name exists in the config.
@@ -192,20 +423,22 @@ func ValidateProvider(cfg *config.Config, providerName string) error {
 	return nil
 }
 
-// GetDefaultProvider returns the first provider name from the config.
+// GetDefaultProvider returns the provider to use when none is selected:
+// default_provider if it names a configured provider, otherwise the first
+// provider in display order (see ListProviders).
 // Returns empty string if no providers are configured.
 func GetDefaultProvider(cfg *config.Config) string {
 	if cfg == nil || len(cfg.Providers) == 0 {
 		return ""
 	}
-	for name := range cfg.Providers {
-		return name
+	if _, exists := cfg.Providers[cfg.DefaultProvider]; exists {
+		return cfg.DefaultProvider
 	}
-	return ""
+	return config.ProviderNames(cfg)[0]
 }
 
 // GetCurrentProvider returns the current provider from config.
-// If current_provider is not set, returns the first available provider.
+// If current_provider is not set, returns the default provider.
 // Returns empty string if no providers are configured.
 func GetCurrentProvider(cfg *config.Config) string {
 	if cfg == nil {
@@ -219,7 +452,7 @@ func GetCurrentProvider(cfg *config.Config) string {
 		}
 	}
 
-	// Fall back to first provider
+	// Fall back to the default provider
 	return GetDefaultProvider(cfg)
 }
 
@@ -1,11 +1,15 @@
 package provider
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/guyskk/ccc/internal/config"
 )
@@ -102,9 +106,9 @@ func TestSwitchWithHook(t *testing.T) {
 			t.Error("Settings should not contain 'env' field")
 		}
 
-		// Verify hooks are NOT present (supervisor cleaned up)
+		// Verify hooks are NOT present (ccc adds no hooks)
 		if _, exists := result.Settings["hooks"]; exists {
-			t.Error("Settings should not contain .hooks. field (supervisor hooks cleaned up)")
+			t.Error("Settings should not contain .hooks. field")
 		}
 
 		// Verify current_provider updated
@@ -141,40 +145,6 @@ func TestSwitchWithHook(t *testing.T) {
 		}
 	})
 
-	t.Run("cleans up old supervisor state/log files", func(t *testing.T) {
-		cleanup := setupTestDir(t)
-		defer cleanup()
-
-		cfg := setupTestConfig(t)
-
-		// Pre-create supervisor state and log files
-		stateDir := config.GetDir() + "/ccc"
-		if err := os.MkdirAll(stateDir, 0755); err != nil {
-			t.Fatal(err)
-		}
-		stateFile := stateDir + "/supervisor-old-session-123.json"
-		logFile := stateDir + "/supervisor-old-session-123.log"
-		if err := os.WriteFile(stateFile, []byte(`{"enabled":true}`), 0644); err != nil {
-			t.Fatal(err)
-		}
-		if err := os.WriteFile(logFile, []byte("old log"), 0644); err != nil {
-			t.Fatal(err)
-		}
-
-		// Switch provider - should clean up the old files
-		_, err := SwitchWithHook(cfg, "glm")
-		if err != nil {
-			t.Fatalf("SwitchWithHook() error = %v", err)
-		}
-
-		// Verify old state/log files are deleted
-		if _, err := os.Stat(stateFile); err == nil {
-			t.Errorf("supervisor state file %s should be deleted", stateFile)
-		}
-		if _, err := os.Stat(logFile); err == nil {
-			t.Errorf("supervisor log file %s should be deleted", logFile)
-		}
-	})
 	t.Run("switch to non-existing provider", func(t *testing.T) {
 		cleanup := setupTestDir(t)
 		defer cleanup()
@@ -355,6 +325,681 @@ func TestSwitchWithHookUserEnv(t *testing.T) {
 	}
 }
 
+func TestSwitchWithHookManagedKeys(t *testing.T) {
+	t.Run("previous provider settings are replaced on switch", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		cfg.Providers["kimi"]["model"] = "kimi-k2-thinking"
+		cfg.Providers["kimi"]["permissions"] = map[string]interface{}{
+			"defaultMode": "acceptEdits",
+		}
+		cfg.Providers["glm"]["model"] = "glm-4.7"
+
+		if _, err := SwitchWithHook(cfg, "kimi"); err != nil {
+			t.Fatalf("SwitchWithHook(kimi) error = %v", err)
+		}
+
+		// User edits settings.json between switches
+		settings, err := config.LoadSettings()
+		if err != nil {
+			t.Fatalf("LoadSettings() error = %v", err)
+		}
+		settings["enabledPlugins"] = map[string]interface{}{"my-plugin": true}
+		if err := config.SaveSettings(settings); err != nil {
+			t.Fatalf("SaveSettings() error = %v", err)
+		}
+
+		result, err := SwitchWithHook(cfg, "glm")
+		if err != nil {
+			t.Fatalf("SwitchWithHook(glm) error = %v", err)
+		}
+
+		if result.Settings["model"] != "glm-4.7" {
+			t.Errorf("model = %v, want glm-4.7 (kimi value must not stick)", result.Settings["model"])
+		}
+		if _, exists := result.Settings["permissions"]; exists {
+			t.Errorf("permissions from kimi should be removed, got %v", result.Settings["permissions"])
+		}
+		if result.Settings["alwaysThinkingEnabled"] != true {
+			t.Errorf("alwaysThinkingEnabled = %v, want true (from base)", result.Settings["alwaysThinkingEnabled"])
+		}
+		if _, exists := result.Settings["enabledPlugins"]; !exists {
+			t.Error("user-added enabledPlugins should be preserved")
+		}
+	})
+
+	t.Run("user edits to ccc-written values are kept", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		cfg.Providers["kimi"]["model"] = "kimi-k2-thinking"
+		cfg.Providers["glm"]["model"] = "glm-4.7"
+
+		if _, err := SwitchWithHook(cfg, "kimi"); err != nil {
+			t.Fatalf("SwitchWithHook(kimi) error = %v", err)
+		}
+
+		settings, err := config.LoadSettings()
+		if err != nil {
+			t.Fatalf("LoadSettings() error = %v", err)
+		}
+		settings["model"] = "my-own-model"
+		if err := config.SaveSettings(settings); err != nil {
+			t.Fatalf("SaveSettings() error = %v", err)
+		}
+
+		result, err := SwitchWithHook(cfg, "glm")
+		if err != nil {
+			t.Fatalf("SwitchWithHook(glm) error = %v", err)
+		}
+		if result.Settings["model"] != "my-own-model" {
+			t.Errorf("model = %v, want my-own-model (user edit wins)", result.Settings["model"])
+		}
+	})
+
+	t.Run("settings written before the record existed are replaced", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		cfg.Providers["kimi"]["model"] = "kimi-k2-thinking"
+		cfg.Providers["glm"]["model"] = "glm-4.7"
+
+		// settings.json as an older ccc left it after switching to kimi
+		if err := config.SaveSettings(map[string]interface{}{
+			"alwaysThinkingEnabled": true,
+			"model":                 "kimi-k2-thinking",
+			"enabledPlugins":        map[string]interface{}{"my-plugin": true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := SwitchWithHook(cfg, "glm")
+		if err != nil {
+			t.Fatalf("SwitchWithHook(glm) error = %v", err)
+		}
+		if result.Settings["model"] != "glm-4.7" {
+			t.Errorf("model = %v, want glm-4.7 (kimi value must not stick)", result.Settings["model"])
+		}
+		if _, exists := result.Settings["enabledPlugins"]; !exists {
+			t.Error("user-added enabledPlugins should be preserved")
+		}
+	})
+
+	t.Run("records written keys", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		if _, err := SwitchWithHook(cfg, "glm"); err != nil {
+			t.Fatalf("SwitchWithHook() error = %v", err)
+		}
+
+		record, err := config.LoadManaged()
+		if err != nil {
+			t.Fatalf("LoadManaged() error = %v", err)
+		}
+		if record == nil {
+			t.Fatal("managed record should be written")
+		}
+		if record.Provider != "glm" {
+			t.Errorf("record.Provider = %q, want glm", record.Provider)
+		}
+		if len(record.Entries) != 1 || record.Entries[0].Path[0] != "alwaysThinkingEnabled" {
+			t.Errorf("record.Entries = %v, want only alwaysThinkingEnabled", record.Entries)
+		}
+	})
+}
+
+func TestSwitchWithHookMergeStrategies(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := setupTestConfig(t)
+	cfg.Merge = map[string]string{"permissions.allow": config.MergeUnion}
+	cfg.Settings["permissions"] = map[string]interface{}{"allow": []interface{}{"Read"}}
+	cfg.Providers["kimi"]["permissions"] = map[string]interface{}{"allow": []interface{}{"WebFetch"}}
+	cfg.Providers["glm"]["permissions"] = map[string]interface{}{"allow": []interface{}{"Bash(git:*)"}}
+
+	if err := config.SaveSettings(map[string]interface{}{
+		"permissions": map[string]interface{}{"allow": []interface{}{"Edit"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := SwitchWithHook(cfg, "kimi")
+	if err != nil {
+		t.Fatalf("SwitchWithHook(kimi) error = %v", err)
+	}
+	allow := result.Settings["permissions"].(map[string]interface{})["allow"]
+	if fmt.Sprint(allow) != "[Read WebFetch Edit]" {
+		t.Errorf("allow = %v, want base, provider and user entries", allow)
+	}
+
+	// kimi's entries don't stick to the user's list on the next switch
+	result, err = SwitchWithHook(cfg, "glm")
+	if err != nil {
+		t.Fatalf("SwitchWithHook(glm) error = %v", err)
+	}
+	allow = result.Settings["permissions"].(map[string]interface{})["allow"]
+	if fmt.Sprint(allow) != "[Read Bash(git:*) Edit]" {
+		t.Errorf("allow = %v, want kimi's entry replaced by glm's", allow)
+	}
+}
+
+func TestSwitchWithHookNullDeletes(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := setupTestConfig(t)
+	cfg.Providers["glm"]["alwaysThinkingEnabled"] = nil
+	config.GetEnv(cfg.Providers["glm"])["DISABLE_TELEMETRY"] = nil
+	cfg.Project = &config.ProjectConfig{Settings: map[string]interface{}{
+		"statusLine": map[string]interface{}{"$literal": nil},
+	}}
+
+	result, err := SwitchWithHook(cfg, "glm")
+	if err != nil {
+		t.Fatalf("SwitchWithHook() error = %v", err)
+	}
+	if _, exists := result.Settings["alwaysThinkingEnabled"]; exists {
+		t.Errorf("alwaysThinkingEnabled = %v, want it removed by the provider", result.Settings["alwaysThinkingEnabled"])
+	}
+	if value, exists := result.Settings["statusLine"]; !exists || value != nil {
+		t.Errorf("statusLine = %v (exists %v), want a literal null", value, exists)
+	}
+	for _, pair := range result.EnvVars {
+		if pair.Key == "DISABLE_TELEMETRY" {
+			t.Errorf("DISABLE_TELEMETRY = %q, want it unset by the provider", pair.Value)
+		}
+	}
+}
+
+func TestSwitchWithHookSecretRefs(t *testing.T) {
+	t.Run("resolves references for the subprocess only", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		secretFile := filepath.Join(config.GetDir(), "glm-token")
+		if err := os.WriteFile(secretFile, []byte("sk-secret-from-file\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := setupTestConfig(t)
+		config.GetEnv(cfg.Providers["glm"])["ANTHROPIC_AUTH_TOKEN"] = "file:" + secretFile
+
+		result, err := SwitchWithHook(cfg, "glm")
+		if err != nil {
+			t.Fatalf("SwitchWithHook() error = %v", err)
+		}
+
+		envMap := make(map[string]string)
+		for _, pair := range result.EnvVars {
+			envMap[pair.Key] = pair.Value
+		}
+		if envMap["ANTHROPIC_AUTH_TOKEN"] != "sk-secret-from-file" {
+			t.Errorf("ANTHROPIC_AUTH_TOKEN = %q, want resolved file content", envMap["ANTHROPIC_AUTH_TOKEN"])
+		}
+
+		// The reference, not the secret, stays in ccc.json
+		data, err := os.ReadFile(config.GetConfigPath())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "sk-secret-from-file") {
+			t.Error("resolved secret must never be written to ccc.json")
+		}
+	})
+
+	t.Run("unresolvable reference fails before writing", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		config.GetEnv(cfg.Providers["glm"])["ANTHROPIC_AUTH_TOKEN"] = "env:CCC_TEST_UNSET_TOKEN"
+
+		_, err := SwitchWithHook(cfg, "glm")
+		if err == nil {
+			t.Fatal("SwitchWithHook() should fail for an unresolvable reference")
+		}
+		if !strings.Contains(err.Error(), "ANTHROPIC_AUTH_TOKEN") || !strings.Contains(err.Error(), "provider 'glm'") {
+			t.Errorf("error should name provider and key, got: %v", err)
+		}
+		if _, err := os.Stat(config.GetSettingsPath()); !os.IsNotExist(err) {
+			t.Error("settings.json must not be written when env resolution fails")
+		}
+	})
+
+	t.Run("expands variables and reports every undefined one", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+		t.Setenv("CCC_TEST_GLM_HOST", "open.bigmodel.cn")
+
+		cfg := setupTestConfig(t)
+		env := config.GetEnv(cfg.Providers["glm"])
+		env["ANTHROPIC_BASE_URL"] = "https://${CCC_TEST_GLM_HOST}/api/anthropic"
+		env["ANTHROPIC_MODEL"] = "${CCC_TEST_UNSET_MODEL:-glm-4.7}"
+
+		result, err := SwitchWithHook(cfg, "glm")
+		if err != nil {
+			t.Fatalf("SwitchWithHook() error = %v", err)
+		}
+		envMap := make(map[string]string)
+		for _, pair := range result.EnvVars {
+			envMap[pair.Key] = pair.Value
+		}
+		if envMap["ANTHROPIC_BASE_URL"] != "https://open.bigmodel.cn/api/anthropic" {
+			t.Errorf("ANTHROPIC_BASE_URL = %q", envMap["ANTHROPIC_BASE_URL"])
+		}
+		if envMap["ANTHROPIC_MODEL"] != "glm-4.7" {
+			t.Errorf("ANTHROPIC_MODEL = %q, want default glm-4.7", envMap["ANTHROPIC_MODEL"])
+		}
+
+		env["ANTHROPIC_AUTH_TOKEN"] = "${CCC_TEST_UNSET_TOKEN}"
+		env["API_TIMEOUT"] = "$CCC_TEST_UNSET_TIMEOUT"
+		_, err = SwitchWithHook(cfg, "glm")
+		if err == nil {
+			t.Fatal("SwitchWithHook() should fail for undefined variables")
+		}
+		for _, want := range []string{"${CCC_TEST_UNSET_TOKEN} is not set", "$CCC_TEST_UNSET_TIMEOUT is not set"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error should contain %q, got: %v", want, err)
+			}
+		}
+	})
+}
+
+func TestSwitchWithHookExtends(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := setupTestConfig(t)
+	cfg.Providers["glm-air"] = map[string]interface{}{
+		"extends": "glm",
+		"model":   "glm-4.5-air",
+		"env":     map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.5-air"},
+	}
+
+	result, err := SwitchWithHook(cfg, "glm-air")
+	if err != nil {
+		t.Fatalf("SwitchWithHook() error = %v", err)
+	}
+
+	envMap := make(map[string]string)
+	for _, pair := range result.EnvVars {
+		envMap[pair.Key] = pair.Value
+	}
+	if envMap["ANTHROPIC_MODEL"] != "glm-4.5-air" {
+		t.Errorf("ANTHROPIC_MODEL = %q, want glm-4.5-air", envMap["ANTHROPIC_MODEL"])
+	}
+	if envMap["ANTHROPIC_AUTH_TOKEN"] != "sk-glm-xxx" {
+		t.Errorf("ANTHROPIC_AUTH_TOKEN = %q, want inherited sk-glm-xxx", envMap["ANTHROPIC_AUTH_TOKEN"])
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings() error = %v", err)
+	}
+	if _, exists := settings["extends"]; exists {
+		t.Error("extends must not be written to settings.json")
+	}
+	if settings["model"] != "glm-4.5-air" {
+		t.Errorf("model = %v, want glm-4.5-air", settings["model"])
+	}
+	if cfg.CurrentProvider != "glm-air" {
+		t.Errorf("CurrentProvider = %q, want glm-air", cfg.CurrentProvider)
+	}
+
+	cfg.Providers["loop"] = map[string]interface{}{"extends": "loop"}
+	if _, err := SwitchWithHook(cfg, "loop"); err == nil || !strings.Contains(err.Error(), "extends cycle") {
+		t.Errorf("SwitchWithHook() error = %v, want extends cycle", err)
+	}
+}
+
+func TestSwitchWithHookLocked(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	originalTimeout := config.LockTimeout
+	config.LockTimeout = 100 * time.Millisecond
+	defer func() { config.LockTimeout = originalTimeout }()
+
+	// Simulate another ccc process in the middle of a switch
+	unlock, err := config.Lock()
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer unlock()
+
+	_, err = SwitchWithHook(setupTestConfig(t), "glm")
+	if err == nil || !strings.Contains(err.Error(), "another ccc process") {
+		t.Fatalf("SwitchWithHook() error = %v, want lock error", err)
+	}
+	if _, err := os.Stat(config.GetSettingsPath()); !os.IsNotExist(err) {
+		t.Error("settings.json must not be written without the lock")
+	}
+	if _, err := os.Stat(config.GetConfigPath()); !os.IsNotExist(err) {
+		t.Error("ccc.json must not be written without the lock")
+	}
+}
+
+func TestSwitchWithHookKeepsConcurrentEdits(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	if err := config.Save(setupTestConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another ccc process adds a provider after cfg was loaded
+	other, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Providers["minimax"] = map[string]interface{}{"model": "MiniMax-M2"}
+	if err := config.Save(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SwitchWithHook(cfg, "kimi"); err != nil {
+		t.Fatalf("SwitchWithHook() error = %v", err)
+	}
+	if cfg.CurrentProvider != "kimi" {
+		t.Errorf("CurrentProvider = %q, want kimi", cfg.CurrentProvider)
+	}
+
+	saved, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.CurrentProvider != "kimi" {
+		t.Errorf("saved CurrentProvider = %q, want kimi", saved.CurrentProvider)
+	}
+	if _, exists := saved.Providers["minimax"]; !exists {
+		t.Error("provider added after Load was lost by the switch")
+	}
+}
+
+func TestSwitchWithHookSnapshots(t *testing.T) {
+	cleanup := setupTestDir(t)
+	defer cleanup()
+
+	cfg := setupTestConfig(t)
+	reasons := func() []string {
+		snapshots, err := config.ListSnapshots()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reasons []string
+		for _, s := range snapshots {
+			reasons = append(reasons, s.Reason)
+		}
+		return reasons
+	}
+
+	for _, name := range []string{"glm", "glm", "kimi"} {
+		if _, err := SwitchWithHook(cfg, name); err != nil {
+			t.Fatalf("SwitchWithHook(%s) error = %v", name, err)
+		}
+	}
+	// Switching to the current provider again changes nothing
+	if got, want := reasons(), []string{"switch to glm", "switch to kimi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("snapshots = %q, want %q", got, want)
+	}
+
+	// A switch that is rolled back leaves no snapshot
+	original := saveConfig
+	saveConfig = func(*config.Config) error { return errors.New("injected failure") }
+	defer func() { saveConfig = original }()
+	if _, err := SwitchWithHook(cfg, "glm"); err == nil {
+		t.Fatal("SwitchWithHook() should fail")
+	}
+	if got := reasons(); len(got) != 2 {
+		t.Errorf("snapshots after rollback = %q, want no new one", got)
+	}
+}
+
+func TestSwitchWithHookRollback(t *testing.T) {
+	injected := errors.New("injected failure")
+	steps := []struct {
+		name   string
+		inject func() func()
+	}{
+		{"settings.json", func() func() {
+			original := saveSettings
+			saveSettings = func(map[string]interface{}) error { return injected }
+			return func() { saveSettings = original }
+		}},
+		{"managed record", func() func() {
+			original := saveManaged
+			saveManaged = func(*config.ManagedRecord) error { return injected }
+			return func() { saveManaged = original }
+		}},
+		{"ccc.json", func() func() {
+			original := saveConfig
+			saveConfig = func(*config.Config) error { return injected }
+			return func() { saveConfig = original }
+		}},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			cleanup := setupTestDir(t)
+			defer cleanup()
+
+			// State left by a previous switch to kimi, plus a user edit
+			cfg := setupTestConfig(t)
+			cfg.Providers["kimi"]["model"] = "kimi-model"
+			if _, err := SwitchWithHook(cfg, "kimi"); err != nil {
+				t.Fatalf("initial SwitchWithHook() error = %v", err)
+			}
+			settings, _ := config.LoadSettings()
+			settings["theme"] = "dark"
+			if err := config.SaveSettings(settings); err != nil {
+				t.Fatal(err)
+			}
+			paths := []string{config.GetSettingsPath(), config.GetManagedPath(), config.GetConfigPath()}
+			before := make(map[string]string)
+			for _, path := range paths {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				before[path] = string(data)
+			}
+
+			restore := step.inject()
+			defer restore()
+
+			_, err := SwitchWithHook(cfg, "glm")
+			if !errors.Is(err, injected) {
+				t.Fatalf("SwitchWithHook() error = %v, want injected failure", err)
+			}
+			if cfg.CurrentProvider != "kimi" {
+				t.Errorf("CurrentProvider = %q, want kimi after rollback", cfg.CurrentProvider)
+			}
+			for _, path := range paths {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Errorf("%s not restored: %v", filepath.Base(path), err)
+					continue
+				}
+				if string(data) != before[path] {
+					t.Errorf("%s changed after failed switch:\n%s\nwant:\n%s", filepath.Base(path), data, before[path])
+				}
+			}
+		})
+	}
+
+	t.Run("files created by a failed first switch are removed", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		original := saveConfig
+		saveConfig = func(*config.Config) error { return injected }
+		defer func() { saveConfig = original }()
+
+		if _, err := SwitchWithHook(setupTestConfig(t), "glm"); !errors.Is(err, injected) {
+			t.Fatalf("SwitchWithHook() error = %v, want injected failure", err)
+		}
+		for _, path := range []string{config.GetSettingsPath(), config.GetManagedPath()} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%s should not exist after a failed first switch", filepath.Base(path))
+			}
+		}
+	})
+}
+
+func TestPrepareSession(t *testing.T) {
+	t.Run("writes private settings file without touching shared files", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		cfg.Providers["glm"]["model"] = "glm-4.7"
+
+		userSettings := map[string]interface{}{"model": "user-model"}
+		if err := config.SaveSettings(userSettings); err != nil {
+			t.Fatalf("Failed to save user settings: %v", err)
+		}
+		before, _ := os.ReadFile(config.GetSettingsPath())
+
+		result, err := PrepareSession(cfg, "glm")
+		if err != nil {
+			t.Fatalf("PrepareSession() error = %v", err)
+		}
+		defer os.Remove(result.SettingsPath)
+
+		after, _ := os.ReadFile(config.GetSettingsPath())
+		if string(before) != string(after) {
+			t.Error("PrepareSession() must not modify settings.json")
+		}
+		if _, err := os.Stat(config.GetConfigPath()); !os.IsNotExist(err) {
+			t.Error("PrepareSession() must not write ccc.json")
+		}
+		if cfg.CurrentProvider != "kimi" {
+			t.Errorf("CurrentProvider = %s, want kimi (unchanged)", cfg.CurrentProvider)
+		}
+
+		info, err := os.Stat(result.SettingsPath)
+		if err != nil {
+			t.Fatalf("session settings file should exist: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("session settings mode = %o, want 600", info.Mode().Perm())
+		}
+
+		data, err := os.ReadFile(result.SettingsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"model": "glm-4.7"`) {
+			t.Errorf("session settings should contain provider model, got: %s", data)
+		}
+		if strings.Contains(string(data), "ANTHROPIC_AUTH_TOKEN") {
+			t.Error("session settings must not contain env")
+		}
+
+		envMap := make(map[string]string)
+		for _, pair := range result.EnvVars {
+			envMap[pair.Key] = pair.Value
+		}
+		if envMap["ANTHROPIC_AUTH_TOKEN"] != "sk-glm-xxx" {
+			t.Errorf("ANTHROPIC_AUTH_TOKEN = %v, want sk-glm-xxx", envMap["ANTHROPIC_AUTH_TOKEN"])
+		}
+	})
+
+	t.Run("values of the global provider don't leak into the session", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		cfg := setupTestConfig(t)
+		cfg.Providers["kimi"]["model"] = "kimi-k2-thinking"
+		cfg.Providers["kimi"]["permissions"] = map[string]interface{}{"defaultMode": "acceptEdits"}
+		cfg.Providers["glm"]["alwaysThinkingEnabled"] = nil
+
+		if _, err := SwitchWithHook(cfg, "kimi"); err != nil {
+			t.Fatalf("SwitchWithHook(kimi) error = %v", err)
+		}
+		settings, err := config.LoadSettings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		settings["enabledPlugins"] = map[string]interface{}{"my-plugin": true}
+		if err := config.SaveSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := PrepareSession(cfg, "glm")
+		if err != nil {
+			t.Fatalf("PrepareSession() error = %v", err)
+		}
+		defer os.Remove(result.SettingsPath)
+
+		data, err := os.ReadFile(result.SettingsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var session map[string]interface{}
+		if err := json.Unmarshal(data, &session); err != nil {
+			t.Fatal(err)
+		}
+		record, err := config.LoadManaged()
+		if err != nil || record == nil || len(record.Entries) != 3 {
+			t.Fatalf("LoadManaged() = %v, %v, want kimi's 3 values", record, err)
+		}
+		for _, entry := range record.Entries {
+			if value, exists := config.GetPath(session, entry.Path); !exists || value != nil {
+				t.Errorf("session %v = %v (exists %v), want null over kimi's value", entry.Path, value, exists)
+			}
+		}
+		if _, exists := session["enabledPlugins"]; exists {
+			t.Error("the user's own settings should be left to settings.json")
+		}
+	})
+
+	t.Run("removes stale session files", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		sessionDir := config.GetSessionDir()
+		if err := os.MkdirAll(sessionDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		// PID 0x7ffffffe is far above any real pid_max
+		stale := filepath.Join(sessionDir, "settings-2147483646-abc.json")
+		if err := os.WriteFile(stale, []byte("{}"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := PrepareSession(setupTestConfig(t), "glm")
+		if err != nil {
+			t.Fatalf("PrepareSession() error = %v", err)
+		}
+		defer os.Remove(result.SettingsPath)
+
+		if _, err := os.Stat(stale); !os.IsNotExist(err) {
+			t.Error("stale session file should be removed")
+		}
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		cleanup := setupTestDir(t)
+		defer cleanup()
+
+		if _, err := PrepareSession(setupTestConfig(t), "unknown"); err == nil {
+			t.Fatal("PrepareSession() should error for unknown provider")
+		}
+	})
+}
+
 func TestGetAuthToken(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -440,6 +1085,19 @@ func TestListProviders(t *testing.T) {
 		if len(providers) != 2 {
 			t.Errorf("ListProviders() returned %d providers, want 2", len(providers))
 		}
+		if providers[0] != "glm" || providers[1] != "kimi" {
+			t.Errorf("ListProviders() = %v, want sorted [glm kimi]", providers)
+		}
+	})
+
+	t.Run("order comes first", func(t *testing.T) {
+		cfg := setupTestConfig(t)
+		cfg.Order = []string{"kimi"}
+
+		providers := ListProviders(cfg)
+		if len(providers) != 2 || providers[0] != "kimi" || providers[1] != "glm" {
+			t.Errorf("ListProviders() = %v, want [kimi glm]", providers)
+		}
 	})
 
 	t.Run("nil config", func(t *testing.T) {
@@ -494,10 +1152,29 @@ func TestGetDefaultProvider(t *testing.T) {
 	t.Run("returns first provider", func(t *testing.T) {
 		cfg := setupTestConfig(t)
 
-		provider := GetDefaultProvider(cfg)
-		// Since map iteration order is random, just check it's one of the valid providers
-		if provider != "kimi" && provider != "glm" {
-			t.Errorf("GetDefaultProvider() = %s, want kimi or glm", provider)
+		// Stable across runs: the first name in sorted order
+		for i := 0; i < 10; i++ {
+			if provider := GetDefaultProvider(cfg); provider != "glm" {
+				t.Fatalf("GetDefaultProvider() = %s, want glm", provider)
+			}
+		}
+
+		cfg.Order = []string{"kimi", "glm"}
+		if provider := GetDefaultProvider(cfg); provider != "kimi" {
+			t.Errorf("GetDefaultProvider() with order = %s, want kimi", provider)
+		}
+	})
+
+	t.Run("default_provider", func(t *testing.T) {
+		cfg := setupTestConfig(t)
+		cfg.DefaultProvider = "kimi"
+		if provider := GetDefaultProvider(cfg); provider != "kimi" {
+			t.Errorf("GetDefaultProvider() = %s, want kimi", provider)
+		}
+
+		cfg.DefaultProvider = "removed"
+		if provider := GetDefaultProvider(cfg); provider != "glm" {
+			t.Errorf("GetDefaultProvider() with unknown default_provider = %s, want glm", provider)
 		}
 	})
 
@@ -534,9 +1211,8 @@ func TestGetCurrentProvider(t *testing.T) {
 		cfg.CurrentProvider = ""
 
 		provider := GetCurrentProvider(cfg)
-		// Since map iteration order is random, just check it's one of the valid providers
-		if provider != "kimi" && provider != "glm" {
-			t.Errorf("GetCurrentProvider() with empty current = %s, want kimi or glm", provider)
+		if provider != "glm" {
+			t.Errorf("GetCurrentProvider() with empty current = %s, want glm", provider)
 		}
 	})
 
@@ -552,9 +1228,8 @@ func TestGetCurrentProvider(t *testing.T) {
 		cfg.CurrentProvider = "invalid"
 
 		provider := GetCurrentProvider(cfg)
-		// Since map iteration order is random, just check it's one of the valid providers
-		if provider != "kimi" && provider != "glm" {
-			t.Errorf("GetCurrentProvider() with invalid current = %s, want kimi or glm", provider)
+		if provider != "glm" {
+			t.Errorf("GetCurrentProvider() with invalid current = %s, want glm", provider)
 		}
 	})
 }
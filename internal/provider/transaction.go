@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/guyskk/ccc/internal/config"
+)
+
+// Steps of a provider switch that change files on disk.
+// These variables allow tests to inject a failure at each step.
+var (
+	saveSettings = config.SaveSettings
+	saveManaged  = config.SaveManaged
+	saveConfig   = config.Save
+)
+
+// fileBackup is the content of a file before a switch modified it.
+type fileBackup struct {
+	path    string
+	existed bool
+	data    []byte
+	mode    os.FileMode
+}
+
+// switchTxn records the files a provider switch modifies, so all of them can
+// be put back if a later step fails and the switch never half-applies.
+type switchTxn struct {
+	backups []fileBackup
+}
+
+// backup records the current state of path. Call it before modifying path;
+// only the first backup of a path is kept.
+func (tx *switchTxn) backup(path string) error {
+	for _, b := range tx.backups {
+		if b.path == path {
+			return nil
+		}
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		tx.backups = append(tx.backups, fileBackup{path: path})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	tx.backups = append(tx.backups, fileBackup{
+		path:    path,
+		existed: true,
+		data:    data,
+		mode:    info.Mode().Perm(),
+	})
+	return nil
+}
+
+// rollback restores every backed-up file, removing files that did not exist
+// before. It keeps going after an error and returns the first one.
+func (tx *switchTxn) rollback() error {
+	var firstErr error
+	for i := len(tx.backups) - 1; i >= 0; i-- {
+		b := tx.backups[i]
+		var err error
+		if b.existed {
+			// WriteFileAtomic keeps the current mode; put the original one back too
+			if err = config.WriteFileAtomic(b.path, b.data, b.mode); err == nil {
+				err = os.Chmod(b.path, b.mode)
+			}
+		} else if removeErr := os.Remove(b.path); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = removeErr
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to restore %s: %w", b.path, err)
+		}
+	}
+	return firstErr
+}
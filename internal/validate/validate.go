@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -50,6 +51,41 @@ type Config interface {
 	CurrentProvider() string
 }
 
+// EnvResolver is optionally implemented by a Config whose provider env values
+// may hold references (e.g. file:, env:, cmd:) that must be resolved before
+// validation. ResolveEnv returns the resolved values and, keyed by env name,
+// the reason each unresolvable value failed. Reasons must not contain secrets.
+type EnvResolver interface {
+	ResolveEnv(env map[string]interface{}) (map[string]interface{}, map[string]string)
+}
+
+// ProviderResolver is optionally implemented by a Config whose providers may
+// inherit settings from other providers or other layers. ResolveProvider
+// returns the settings the named provider is launched with; its env is
+// validated as the env of the claude process.
+type ProviderResolver interface {
+	ResolveProvider(name string) (map[string]interface{}, error)
+}
+
+// ProviderLister is optionally implemented by a Config that defines the
+// order providers are listed in. Without it, providers are sorted by name.
+type ProviderLister interface {
+	ProviderNames() []string
+}
+
+// providerNames returns the provider names of cfg in display order.
+func providerNames(cfg Config) []string {
+	if lister, ok := cfg.(ProviderLister); ok {
+		return lister.ProviderNames()
+	}
+	names := make([]string, 0, len(cfg.Providers()))
+	for name := range cfg.Providers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Model represents a model from the /v1/models API response.
 type Model struct {
 	ID string `json:"id"`
@@ -161,6 +197,16 @@ func selectBestModel(models []string) string {
 
 // ValidateProvider validates a single provider configuration.
 func ValidateProvider(cfg Config, providerName string) *ValidationResult {
+	result, authToken := checkProvider(cfg, providerName)
+	testProviderAPI(result, authToken)
+	return result
+}
+
+// checkProvider validates the configuration of a provider, resolving its env
+// references, and returns the result with the auth token for the API test.
+// References may prompt on the terminal (cmd:), so providers are checked one
+// at a time.
+func checkProvider(cfg Config, providerName string) (*ValidationResult, string) {
 	result := &ValidationResult{
 		Provider:  providerName,
 		Valid:     true,
@@ -176,7 +222,18 @@ func ValidateProvider(cfg Config, providerName string) *ValidationResult {
 	if !exists {
 		result.Valid = false
 		result.Errors = append(result.Errors, fmt.Sprintf("Provider '%s' not found in configuration", providerName))
-		return result
+		return result, ""
+	}
+
+	// Validate what will actually be launched, including inherited settings
+	if resolver, ok := cfg.(ProviderResolver); ok {
+		resolved, err := resolver.ResolveProvider(providerName)
+		if err != nil {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("Cannot resolve provider: %v", err))
+			return result, ""
+		}
+		provider = resolved
 	}
 
 	// Extract env from provider config
@@ -191,13 +248,30 @@ func ValidateProvider(cfg Config, providerName string) *ValidationResult {
 		env = make(map[string]interface{})
 	}
 
+	// Resolve references; unresolvable values are reported once, not again as missing
+	var problems map[string]string
+	if resolver, ok := cfg.(EnvResolver); ok {
+		env, problems = resolver.ResolveEnv(env)
+		keys := make([]string, 0, len(problems))
+		for key := range problems {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result.Valid = false
+			result.Errors = append(result.Errors, fmt.Sprintf("Cannot resolve %s: %s", key, problems[key]))
+		}
+	}
+
 	// Check required environment variables
 	baseURL, hasBaseURL := env["ANTHROPIC_BASE_URL"].(string)
 	authToken, hasAuthToken := env["ANTHROPIC_AUTH_TOKEN"].(string)
 
 	if !hasBaseURL || baseURL == "" {
-		result.Valid = false
-		result.Errors = append(result.Errors, "Missing required environment variable: ANTHROPIC_BASE_URL")
+		if _, failed := problems["ANTHROPIC_BASE_URL"]; !failed {
+			result.Valid = false
+			result.Errors = append(result.Errors, "Missing required environment variable: ANTHROPIC_BASE_URL")
+		}
 	} else {
 		result.BaseURL = baseURL
 		// Validate URL format - must be http or https
@@ -214,24 +288,25 @@ func ValidateProvider(cfg Config, providerName string) *ValidationResult {
 		}
 	}
 
-	if !hasAuthToken || authToken == "" {
+	if _, failed := problems["ANTHROPIC_AUTH_TOKEN"]; !failed && (!hasAuthToken || authToken == "") {
 		result.Valid = false
 		result.Errors = append(result.Errors, "Missing required environment variable: ANTHROPIC_AUTH_TOKEN")
 	}
 
 	// Check model if present
-	model := ""
 	if m, ok := env["ANTHROPIC_MODEL"].(string); ok {
-		model = m
-		result.Model = model
+		result.Model = m
 	}
 
-	// Test API connection if config is valid so far
-	if result.Valid && hasBaseURL && hasAuthToken {
-		result.APIStatus = testAPIConnection(baseURL, authToken, model)
-	}
+	return result, authToken
+}
 
-	return result
+// testProviderAPI tests the API connection of a provider checked by
+// checkProvider, if its configuration is valid.
+func testProviderAPI(result *ValidationResult, authToken string) {
+	if result.Valid && result.BaseURL != "" && authToken != "" {
+		result.APIStatus = testAPIConnection(result.BaseURL, authToken, result.Model)
+	}
 }
 
 // testAPIConnection tests if the API endpoint is reachable.
@@ -294,40 +369,42 @@ func testAPIConnection(baseURL, authToken, model string) string {
 	return fmt.Sprintf("HTTP %d", resp.StatusCode)
 }
 
-// ValidateAllProviders validates all configured providers in parallel.
+// ValidateAllProviders validates all configured providers. The configurations
+// are checked one at a time, so secret references that prompt on the terminal
+// don't compete for it; the API connections are then tested in parallel.
+// Results are returned in display order, regardless of which finishes first.
 func ValidateAllProviders(cfg Config) *ValidationSummary {
-	providers := cfg.Providers()
+	names := providerNames(cfg)
 	summary := &ValidationSummary{
-		Total:   len(providers),
-		Results: make([]*ValidationResult, 0, len(providers)),
+		Total:   len(names),
+		Results: make([]*ValidationResult, len(names)),
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	authTokens := make([]string, len(names))
+	for i, name := range names {
+		summary.Results[i], authTokens[i] = checkProvider(cfg, name)
+	}
 
-	for providerName := range providers {
+	var wg sync.WaitGroup
+	for i, result := range summary.Results {
 		wg.Add(1)
-		go func(name string) {
+		go func(result *ValidationResult, authToken string) {
 			defer wg.Done()
-			result := ValidateProvider(cfg, name)
-
-			mu.Lock()
-			summary.Results = append(summary.Results, result)
-
-			if result.Valid {
-				summary.Valid++
-			} else {
-				summary.Invalid++
-			}
-
-			if result.APIStatus != "" && !isAPIStatusOK(result.APIStatus) {
-				summary.Warning++
-			}
-			mu.Unlock()
-		}(providerName)
+			testProviderAPI(result, authToken)
+		}(result, authTokens[i])
 	}
-
 	wg.Wait()
+
+	for _, result := range summary.Results {
+		if result.Valid {
+			summary.Valid++
+		} else {
+			summary.Invalid++
+		}
+		if result.APIStatus != "" && !isAPIStatusOK(result.APIStatus) {
+			summary.Warning++
+		}
+	}
 	return summary
 }
 
@@ -433,7 +510,7 @@ func Run(cfg Config, opts *RunOptions) error {
 		fmt.Println("No current provider set")
 		if len(cfg.Providers()) > 0 {
 			fmt.Println("\nAvailable providers:")
-			for name := range cfg.Providers() {
+			for _, name := range providerNames(cfg) {
 				fmt.Printf("  %s\n", name)
 			}
 		}
@@ -6,7 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // mockConfig implements Config interface for testing.
@@ -213,6 +215,197 @@ func TestValidateProvider(t *testing.T) {
 	}
 }
 
+// resolvingConfig is a mockConfig that also implements EnvResolver.
+// Values starting with "bad:" fail to resolve; "ref:X" resolves to X.
+type resolvingConfig struct {
+	mockConfig
+}
+
+func (r *resolvingConfig) ResolveEnv(env map[string]interface{}) (map[string]interface{}, map[string]string) {
+	resolved := make(map[string]interface{})
+	problems := make(map[string]string)
+	for k, v := range env {
+		s, _ := v.(string)
+		switch {
+		case strings.HasPrefix(s, "bad:"):
+			problems[k] = s + ": not found"
+		case strings.HasPrefix(s, "ref:"):
+			resolved[k] = strings.TrimPrefix(s, "ref:")
+		default:
+			resolved[k] = v
+		}
+	}
+	return resolved, problems
+}
+
+func TestValidateProviderResolvesEnv(t *testing.T) {
+	t.Run("unresolvable reference is reported once", func(t *testing.T) {
+		cfg := &resolvingConfig{mockConfig{providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{
+				"ANTHROPIC_BASE_URL":   "https://example.com",
+				"ANTHROPIC_AUTH_TOKEN": "bad:file:~/.secrets/glm",
+			}},
+		}}}
+
+		result := ValidateProvider(cfg, "glm")
+		if result.Valid {
+			t.Fatal("provider with unresolvable token should be invalid")
+		}
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "Cannot resolve ANTHROPIC_AUTH_TOKEN") {
+			t.Errorf("Errors = %v, want single resolve error", result.Errors)
+		}
+		if result.APIStatus != "" {
+			t.Errorf("API test should be skipped, got status %q", result.APIStatus)
+		}
+	})
+
+	t.Run("resolved values are validated", func(t *testing.T) {
+		cfg := &resolvingConfig{mockConfig{providers: map[string]map[string]interface{}{
+			"glm": {"env": map[string]interface{}{
+				"ANTHROPIC_BASE_URL":   "ref:not-a-url",
+				"ANTHROPIC_AUTH_TOKEN": "ref:sk-x",
+			}},
+		}}}
+
+		result := ValidateProvider(cfg, "glm")
+		if result.Valid {
+			t.Fatal("resolved invalid base URL should be reported")
+		}
+		if result.BaseURL != "not-a-url" {
+			t.Errorf("BaseURL = %q, want resolved value", result.BaseURL)
+		}
+	})
+}
+
+// extendingConfig is a mockConfig that also implements ProviderResolver,
+// merging a provider's env over the env of the provider it extends.
+type extendingConfig struct {
+	mockConfig
+}
+
+func (e *extendingConfig) ResolveProvider(name string) (map[string]interface{}, error) {
+	p := e.providers[name]
+	parent, ok := p["extends"].(string)
+	if !ok {
+		return p, nil
+	}
+	base, exists := e.providers[parent]
+	if !exists {
+		return nil, fmt.Errorf("provider '%s' extends unknown provider '%s'", name, parent)
+	}
+	env := make(map[string]interface{})
+	for _, src := range []map[string]interface{}{base, p} {
+		if m, ok := src["env"].(map[string]interface{}); ok {
+			for k, v := range m {
+				env[k] = v
+			}
+		}
+	}
+	return map[string]interface{}{"env": env}, nil
+}
+
+func TestValidateProviderResolvesExtends(t *testing.T) {
+	cfg := &extendingConfig{mockConfig{providers: map[string]map[string]interface{}{
+		"glm": {"env": map[string]interface{}{
+			"ANTHROPIC_BASE_URL":   "not-a-url",
+			"ANTHROPIC_AUTH_TOKEN": "sk-x",
+		}},
+		"glm-air": {
+			"extends": "glm",
+			"env":     map[string]interface{}{"ANTHROPIC_MODEL": "glm-4.5-air"},
+		},
+		"broken": {"extends": "missing"},
+	}}}
+
+	result := ValidateProvider(cfg, "glm-air")
+	if result.BaseURL != "not-a-url" || result.Model != "glm-4.5-air" {
+		t.Errorf("BaseURL = %q, Model = %q, want inherited base URL and own model", result.BaseURL, result.Model)
+	}
+	for _, e := range result.Errors {
+		if strings.Contains(e, "Missing required") {
+			t.Errorf("inherited env should satisfy required variables, got %q", e)
+		}
+	}
+
+	result = ValidateProvider(cfg, "broken")
+	if result.Valid || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "Cannot resolve provider") {
+		t.Errorf("Errors = %v, want single resolve error", result.Errors)
+	}
+}
+
+// orderedConfig is a mockConfig that also implements ProviderLister.
+type orderedConfig struct {
+	mockConfig
+	order []string
+}
+
+func (o *orderedConfig) ProviderNames() []string {
+	return o.order
+}
+
+func TestValidateAllProvidersOrder(t *testing.T) {
+	// Missing env keeps the API test (and the network) out of this test
+	providers := map[string]map[string]interface{}{
+		"kimi": {}, "glm": {}, "minimax": {}, "88code": {},
+	}
+
+	summary := ValidateAllProviders(&mockConfig{providers: providers})
+	var got []string
+	for _, result := range summary.Results {
+		got = append(got, result.Provider)
+	}
+	if strings.Join(got, ",") != "88code,glm,kimi,minimax" {
+		t.Errorf("results = %v, want sorted by name", got)
+	}
+
+	order := []string{"minimax", "kimi", "88code", "glm"}
+	summary = ValidateAllProviders(&orderedConfig{mockConfig{providers: providers}, order})
+	got = nil
+	for _, result := range summary.Results {
+		got = append(got, result.Provider)
+	}
+	if strings.Join(got, ",") != strings.Join(order, ",") {
+		t.Errorf("results = %v, want %v", got, order)
+	}
+}
+
+// serialConfig is a resolvingConfig that records whether ResolveEnv was
+// ever called while another call was still running.
+type serialConfig struct {
+	resolvingConfig
+	active     atomic.Int32
+	overlapped atomic.Bool
+}
+
+func (s *serialConfig) ResolveEnv(env map[string]interface{}) (map[string]interface{}, map[string]string) {
+	if s.active.Add(1) > 1 {
+		s.overlapped.Store(true)
+	}
+	defer s.active.Add(-1)
+	time.Sleep(5 * time.Millisecond)
+	return s.resolvingConfig.ResolveEnv(env)
+}
+
+func TestValidateAllProvidersResolvesSerially(t *testing.T) {
+	// Unresolvable tokens keep the API test (and the network) out of this test
+	providers := make(map[string]map[string]interface{})
+	for _, name := range []string{"glm", "kimi", "minimax", "88code"} {
+		providers[name] = map[string]interface{}{"env": map[string]interface{}{
+			"ANTHROPIC_BASE_URL":   "https://example.com",
+			"ANTHROPIC_AUTH_TOKEN": "bad:cmd:pass " + name,
+		}}
+	}
+
+	cfg := &serialConfig{resolvingConfig: resolvingConfig{mockConfig{providers: providers}}}
+	summary := ValidateAllProviders(cfg)
+	if summary.Invalid != 4 {
+		t.Errorf("Invalid = %d, want 4", summary.Invalid)
+	}
+	if cfg.overlapped.Load() {
+		t.Error("env references of several providers were resolved at the same time")
+	}
+}
+
 func TestValidateAllProviders(t *testing.T) {
 	tests := []struct {
 		name        string